@@ -2,10 +2,12 @@
 package build
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/chelnak/pdk/internal/config"
 	"github.com/chelnak/pdk/pkg/build"
 	"github.com/chelnak/ysmrr"
 	"github.com/spf13/cobra"
@@ -63,8 +65,15 @@ func buildRunE(cmd *cobra.Command, args []string) error {
 	sm.Start()
 	defer sm.Stop()
 
+	ctx := cmd.Context()
+	if timeout := config.Timeout(config.Config.BuildTimeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	builder := build.NewBuilder()
-	archive, err := builder.Build(sourceDir, targetDir)
+	archive, err := builder.Build(ctx, sourceDir, targetDir)
 	if err != nil {
 		spinner.Error()
 		return err
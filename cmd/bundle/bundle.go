@@ -0,0 +1,67 @@
+// Package bundle contains commands for moving templates, tools and runtime
+// images to machines without network access.
+package bundle
+
+import (
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/pkg/install"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/spf13/cobra"
+)
+
+// GetBundleCmd returns a cobra.Command that implements functionality for
+// exporting and importing bundles.
+func GetBundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Commands for moving templates and tools to air-gapped machines.",
+		Long: `Commands for moving templates and tools to air-gapped machines.
+
+A bundle is a single tar.gz that holds installed templates, tools and, optionally, runtime images
+along with a manifest of their checksums.`,
+	}
+
+	cmd.AddCommand(getExportCmd())
+	cmd.AddCommand(getImportCmd())
+
+	return cmd
+}
+
+// selectPackages returns the packages installed in root that match the given
+// selectors. A selector is either author/id, which matches every installed
+// version, or author/id/version.
+func selectPackages(root string, selectors []string) ([]install.InstalledPackage, error) {
+	packages, err := install.List(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []install.InstalledPackage
+	for _, selector := range selectors {
+		found := false
+		for _, p := range packages {
+			if selector == p.Name() || selector == p.Name()+"/"+p.Version {
+				selected = append(selected, p)
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, pdk_errors.New(pdk_errors.NotFound, "%s is not installed in %s", selector, root)
+		}
+	}
+
+	return selected, nil
+}
+
+// allPackages returns every package installed in the template and tool
+// paths.
+func allPackages() (templates, tools []install.InstalledPackage, err error) {
+	templates, err = install.List(config.TemplatePath())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tools, err = install.List(config.ToolPath())
+	return templates, tools, err
+}
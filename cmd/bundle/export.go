@@ -0,0 +1,101 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chelnak/pdk/cmd/completion"
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/pkg/bundle"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/ysmrr"
+	"github.com/spf13/cobra"
+)
+
+var (
+	name      string
+	targetDir string
+	templates []string
+	tools     []string
+	images    []string
+)
+
+func getExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Exports installed templates and tools to a bundle.",
+		Long: `Exports installed templates and tools to a bundle.
+
+Every installed template and tool is exported unless --template or --tool is given. Both accept
+author/id, which selects every installed version, or author/id/version.
+
+Runtime images are saved with 'docker save' when --image is given.`,
+		RunE: exportRunE,
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "pdk-bundle", "The name of the bundle. The bundle is written to <target>/<name>.tar.gz.")
+	cmd.Flags().StringVarP(&targetDir, "target", "t", "", "The directory where the bundle will be written. Defaults to the current working directory.")
+	cmd.Flags().StringSliceVar(&templates, "template", nil, "A template to export. Can be given more than once.")
+	cmd.Flags().StringSliceVar(&tools, "tool", nil, "A tool to export. Can be given more than once.")
+	cmd.Flags().StringSliceVar(&images, "image", nil, "A runtime image to export. Can be given more than once.")
+
+	_ = cmd.RegisterFlagCompletionFunc("template", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completion.Templates(cmd, nil, toComplete)
+	})
+	_ = cmd.RegisterFlagCompletionFunc("tool", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completion.Tools(cmd, nil, toComplete)
+	})
+
+	return cmd
+}
+
+func exportRunE(cmd *cobra.Command, args []string) error {
+	opts := bundle.ExportOptions{
+		Name:      name,
+		TargetDir: targetDir,
+		Images:    images,
+	}
+
+	if opts.TargetDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		opts.TargetDir = wd
+	}
+
+	var err error
+	if len(templates) == 0 && len(tools) == 0 {
+		opts.Templates, opts.Tools, err = allPackages()
+	} else {
+		opts.Templates, err = selectPackages(config.TemplatePath(), templates)
+		if err == nil {
+			opts.Tools, err = selectPackages(config.ToolPath(), tools)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(opts.Templates) == 0 && len(opts.Tools) == 0 && len(opts.Images) == 0 {
+		return pdk_errors.New(pdk_errors.NotFound, "there is nothing to export. Install a template or tool first")
+	}
+
+	sm := ysmrr.NewSpinnerManager()
+	spinner := sm.AddSpinner("Exporting bundle...")
+	sm.Start()
+	defer sm.Stop()
+
+	archive, err := bundle.NewBundler().Export(cmd.Context(), opts)
+	if err != nil {
+		spinner.Error()
+		return err
+	}
+
+	message := fmt.Sprintf("Exported %d templates, %d tools and %d images to %s\n", len(opts.Templates), len(opts.Tools), len(opts.Images), archive)
+	spinner.UpdateMessage(message)
+	spinner.Complete()
+	return nil
+}
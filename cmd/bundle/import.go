@@ -0,0 +1,77 @@
+package bundle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/pkg/bundle"
+	"github.com/chelnak/ysmrr"
+	"github.com/spf13/cobra"
+)
+
+var (
+	force      bool
+	skipImages bool
+)
+
+func getImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <bundle>",
+		Short: "Imports the templates, tools and images in a bundle.",
+		Long: `Imports the templates, tools and images in a bundle.
+
+Every checksum in the bundle manifest is verified before anything is installed. Templates are installed
+to the configured template_path and tools to the configured tool_path. Packages that are already
+installed are skipped unless --force is given. Runtime images are loaded with 'docker load'.
+
+No network access is needed, so bundles can be imported in offline mode.`,
+		Args: cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return []string{"tar.gz", "tgz"}, cobra.ShellCompDirectiveFilterFileExt
+		},
+		RunE: importRunE,
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Replace templates and tools that are already installed.")
+	cmd.Flags().BoolVar(&skipImages, "skip-images", false, "Do not load the runtime images in the bundle.")
+
+	return cmd
+}
+
+func importRunE(cmd *cobra.Command, args []string) error {
+	sm := ysmrr.NewSpinnerManager()
+	spinner := sm.AddSpinner("Importing bundle...")
+	sm.Start()
+	defer sm.Stop()
+
+	result, err := bundle.NewBundler().Import(cmd.Context(), args[0], bundle.ImportOptions{
+		TemplatePath: config.TemplatePath(),
+		ToolPath:     config.ToolPath(),
+		Force:        force,
+		SkipImages:   skipImages,
+	})
+	if err != nil {
+		spinner.Error()
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Imported %d packages and %d images from %s\n", len(result.Installed), len(result.Images), args[0])
+	for _, path := range result.Installed {
+		fmt.Fprintf(&b, "  installed %s\n", path)
+	}
+	for _, skipped := range result.Skipped {
+		fmt.Fprintf(&b, "  skipped %s, already installed\n", skipped)
+	}
+	for _, image := range result.Images {
+		fmt.Fprintf(&b, "  loaded %s\n", image)
+	}
+
+	spinner.UpdateMessage(b.String())
+	spinner.Complete()
+	return nil
+}
@@ -0,0 +1,119 @@
+// Package completion contains the command for generating shell completion
+// scripts and helpers that provide dynamic completions to other commands.
+package completion
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/pkg/install"
+	"github.com/spf13/cobra"
+)
+
+// GetCompletionCmd returns a cobra.Command that generates shell completion
+// scripts for the cli.
+func GetCompletionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completion [bash|zsh|fish|powershell]",
+		Short: "Generates a shell completion script.",
+		Long: `Generates a shell completion script.
+
+To load completions for the current session:
+
+  bash:       source <(pdk completion bash)
+  zsh:        source <(pdk completion zsh)
+  fish:       pdk completion fish | source
+  powershell: pdk completion powershell | Out-String | Invoke-Expression
+
+To load completions for every session, write the output to the completions directory of your shell.`,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.ExactValidArgs(1),
+		DisableFlagsInUseLine: true,
+		RunE:                  completionRunE,
+	}
+
+	return cmd
+}
+
+func completionRunE(cmd *cobra.Command, args []string) error {
+	root := cmd.Root()
+
+	switch args[0] {
+	case "bash":
+		return root.GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		return root.GenZshCompletion(os.Stdout)
+	case "fish":
+		return root.GenFishCompletion(os.Stdout, true)
+	case "powershell":
+		return root.GenPowerShellCompletionWithDesc(os.Stdout)
+	default:
+		return fmt.Errorf("unsupported shell %q", args[0])
+	}
+}
+
+// Templates completes the names of the templates that are installed in the
+// template path.
+func Templates(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return installedNames(config.TemplatePath(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// Tools completes the names of the tools that are installed in the tool path.
+func Tools(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	return installedNames(config.ToolPath(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// ConfigKeys completes the names of the supported configuration keys.
+func ConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return filter(config.Keys(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// ConfigValues completes the allowed values of the configuration key that
+// has been passed with the --key flag.
+func ConfigValues(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	key, err := cmd.Flags().GetString("key")
+	if err != nil || key == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return filter(config.AllowedValues(key), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func installedNames(root, toComplete string) []string {
+	packages, err := install.List(root)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, p := range packages {
+		if !seen[p.Name()] {
+			seen[p.Name()] = true
+			names = append(names, p.Name())
+		}
+	}
+
+	return filter(names, toComplete)
+}
+
+func filter(values []string, prefix string) []string {
+	var matches []string
+	for _, v := range values {
+		if strings.HasPrefix(v, prefix) {
+			matches = append(matches, v)
+		}
+	}
+
+	return matches
+}
@@ -12,6 +12,9 @@ func GetConfigCmd() *cobra.Command {
 
 	cmd.AddCommand(getShowCmd())
 	cmd.AddCommand(getSetCmd())
+	cmd.AddCommand(getProfileCmd())
+	cmd.AddCommand(getMigrateCmd())
+	cmd.AddCommand(getEditCmd())
 
 	return cmd
 }
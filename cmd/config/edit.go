@@ -0,0 +1,170 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// errorCommentPrefix marks the lines that are added to the top of the file
+// when it fails validation. They are removed before the file is validated
+// again.
+const errorCommentPrefix = "# pdk: "
+
+func getEditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "edit",
+		Short: "Opens the active config file in your editor.",
+		Long: `Opens the active config file in your editor.
+
+The editor is taken from the VISUAL or EDITOR environment variables. When the editor is closed
+the config is validated. If it is invalid the editor is opened again with the errors at the top
+of the file. The original config file is only replaced once the config is valid. Save an empty
+file to abort.`,
+		Args: cobra.NoArgs,
+		RunE: editRunE,
+	}
+
+	return cmd
+}
+
+func editRunE(cmd *cobra.Command, args []string) error {
+	configFile := viper.ConfigFileUsed()
+
+	original, err := os.ReadFile(configFile) // #nosec G304 -- path is the active config file
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.FileSystem, err, "failed to read config file")
+	}
+
+	tmp, err := os.CreateTemp("", "pdk-config-*.yaml")
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create temp file")
+	}
+
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := tmp.Close(); err != nil {
+		return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create temp file")
+	}
+
+	data := original
+	for {
+		if err := os.WriteFile(tmp.Name(), data, 0600); err != nil {
+			return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not write %s", tmp.Name())
+		}
+
+		if err := openEditor(tmp.Name()); err != nil {
+			return err
+		}
+
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not read %s", tmp.Name())
+		}
+
+		edited = stripErrorComments(edited)
+		if len(bytes.TrimSpace(edited)) == 0 {
+			return pdk_errors.New(pdk_errors.Canceled, "aborted: the config file was empty")
+		}
+
+		if bytes.Equal(edited, original) {
+			fmt.Println("No changes made.")
+			return nil
+		}
+
+		if err := config.Validate(edited); err != nil {
+			data = addErrorComment(edited, err)
+			continue
+		}
+
+		if err := writeFileAtomic(configFile, edited); err != nil {
+			return pdk_errors.Wrap(pdk_errors.FileSystem, err, "failed to write config file")
+		}
+
+		fmt.Printf("Saved %s\n", configFile)
+		return nil
+	}
+}
+
+func openEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	fields := strings.Fields(editor)
+	c := exec.Command(fields[0], append(fields[1:], path)...) // #nosec G204 -- the editor is chosen by the user
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	if err := c.Run(); err != nil {
+		return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "editor %q failed. Set VISUAL or EDITOR to the editor to use", editor)
+	}
+
+	return nil
+}
+
+func addErrorComment(data []byte, validationErr error) []byte {
+	var b bytes.Buffer
+	b.WriteString(errorCommentPrefix + "The config is invalid and has not been saved.\n")
+	for _, line := range strings.Split(validationErr.Error(), "\n") {
+		b.WriteString(errorCommentPrefix + line + "\n")
+	}
+	b.WriteString(errorCommentPrefix + "Fix the errors and save the file, or save an empty file to abort.\n")
+	b.Write(data)
+
+	return b.Bytes()
+}
+
+func stripErrorComments(data []byte) []byte {
+	var b bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte(errorCommentPrefix)) {
+			continue
+		}
+		b.Write(line)
+	}
+
+	return b.Bytes()
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it over
+// path so that a failed write never leaves a partial config behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/spf13/viper"
+)
+
+// editor writes a script that replaces the file it is given with each of
+// contents in turn and points EDITOR at it.
+func editor(t *testing.T, contents ...string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the test editor is a shell script")
+	}
+
+	dir := t.TempDir()
+	count := filepath.Join(dir, "count")
+	script := fmt.Sprintf("#!/bin/sh\nn=$(cat %s 2>/dev/null || echo 0)\necho $((n + 1)) > %s\n", count, count)
+	for i, content := range contents {
+		name := filepath.Join(dir, fmt.Sprintf("content%d", i))
+		if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		script += fmt.Sprintf("[ \"$n\" = %d ] && cp %s \"$1\"\n", i, name)
+	}
+
+	path := filepath.Join(dir, "editor")
+	if err := os.WriteFile(path, []byte(script+"exit 0\n"), 0700); err != nil { // #nosec G306 -- the script must be executable
+		t.Fatal(err)
+	}
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", path)
+}
+
+func configFile(t *testing.T, content string) string {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+
+	path := filepath.Join(t.TempDir(), ".pdk.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigFile(path)
+
+	return path
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestEditSavesValidConfig(t *testing.T) {
+	path := configFile(t, "backend: docker\n")
+	editor(t, "# edited\nbackend: local\n")
+
+	if err := editRunE(nil, nil); err != nil {
+		t.Fatalf("editRunE() returned an error: %v", err)
+	}
+
+	if got := read(t, path); got != "# edited\nbackend: local\n" {
+		t.Errorf("got %q", got)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files next to the config, want the temp file to be renamed over it", len(entries))
+	}
+}
+
+func TestEditReopensInvalidConfig(t *testing.T) {
+	path := configFile(t, "backend: docker\n")
+	editor(t, "backend: podman\n", "backend: local\n")
+
+	if err := editRunE(nil, nil); err != nil {
+		t.Fatalf("editRunE() returned an error: %v", err)
+	}
+
+	if got := read(t, path); got != "backend: local\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestEditAbortsOnEmptyFile(t *testing.T) {
+	path := configFile(t, "backend: docker\n")
+	editor(t, "backend: podman\n", "")
+
+	err := editRunE(nil, nil)
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.Canceled {
+		t.Errorf("got kind %s, want %s: %v", kind.Code, pdk_errors.Canceled.Code, err)
+	}
+
+	if got := read(t, path); got != "backend: docker\n" {
+		t.Errorf("the config file was changed: %q", got)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".pdk.yaml")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte("new")); err != nil {
+		t.Fatalf("writeFileAtomic() returned an error: %v", err)
+	}
+
+	if got := read(t, path); got != "new" {
+		t.Errorf("got %q, want %q", got, "new")
+	}
+
+	err := writeFileAtomic(filepath.Join(t.TempDir(), "missing", ".pdk.yaml"), []byte("new"))
+	if err == nil {
+		t.Error("writeFileAtomic() returned no error for a missing directory")
+	}
+}
+
+func TestErrorComments(t *testing.T) {
+	data := []byte("backend: podman\n")
+	commented := addErrorComment(data, pdk_errors.New(pdk_errors.InvalidConfig, "first\nsecond"))
+
+	for _, line := range []string{"# pdk: first\n", "# pdk: second\n"} {
+		if !strings.Contains(string(commented), line) {
+			t.Errorf("%q does not contain %q", commented, line)
+		}
+	}
+
+	if got := stripErrorComments(commented); string(got) != string(data) {
+		t.Errorf("got %q, want %q", got, data)
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/internal/utils/terminal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var dryRun bool
+
+func getMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrates the config file to the latest config version.",
+		Long: `Migrates the config file to the latest config version.
+
+Config files are migrated automatically when they are loaded, but only the default config
+file is saved, and only if it can be written. Files given with --config are migrated in
+memory and left as they are until this command is run. The changes can be previewed with
+--dry-run before they are applied. A backup of the original file is written next to it,
+and the migrated file keeps the format of the original.`,
+		Annotations: map[string]string{config.SkipMigrationAnnotation: ""},
+		RunE:        migrateRunE,
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes that would be made without writing them.")
+	cmd.Flags().BoolVarP(&noColor, "no-color", "n", false, "Disable color output")
+
+	return cmd
+}
+
+func migrateRunE(cmd *cobra.Command, args []string) error {
+	if !terminal.IsTTY() && !noColor {
+		noColor = true
+	}
+
+	result, err := config.Migrate(viper.ConfigFileUsed(), dryRun)
+	if err != nil {
+		return err
+	}
+
+	if result.FromVersion == result.ToVersion {
+		fmt.Printf("%s is already at version %d.\n", result.File, result.ToVersion)
+		return nil
+	}
+
+	for _, applied := range result.Applied {
+		fmt.Printf("• %s\n", applied)
+	}
+	fmt.Println()
+
+	if err := config.PrintDiff(result.Diff, noColor, os.Stdout); err != nil {
+		return err
+	}
+
+	if !dryRun {
+		fmt.Printf("\nMigrated %s from v%d to v%d. A backup was saved to %s\n", result.File, result.FromVersion, result.ToVersion, result.BackupFile)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var profileValues map[string]string
+
+func getProfileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Commands for working with named configuration profiles.",
+		Long: `Commands for working with named configuration profiles.
+
+Profiles are stored under the profiles key of the config file. The values of the selected profile
+are applied on top of the rest of the configuration. A profile can be selected with the --profile flag,
+the PDK_PROFILE environment variable or by setting a default with 'pdk config profile use'.`,
+	}
+
+	cmd.AddCommand(getProfileListCmd())
+	cmd.AddCommand(getProfileUseCmd())
+	cmd.AddCommand(getProfileCreateCmd())
+
+	return cmd
+}
+
+func getProfileListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists all configuration profiles. The active profile is marked with *.",
+		Long:  "Lists all configuration profiles. The active profile is marked with *.",
+		Args:  cobra.NoArgs,
+		RunE:  profileListRunE,
+	}
+
+	return cmd
+}
+
+func profileListRunE(cmd *cobra.Command, args []string) error {
+	for _, name := range config.Profiles() {
+		marker := " "
+		if name == config.Config.Profile {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, name)
+	}
+
+	return nil
+}
+
+func getProfileUseCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "use <name>",
+		Short: "Sets the default configuration profile. Pass an empty name to clear it.",
+		Long:  "Sets the default configuration profile. Pass an empty name to clear it.",
+		Args:  cobra.ExactArgs(1),
+		RunE:  profileUseRunE,
+	}
+
+	return cmd
+}
+
+func profileUseRunE(cmd *cobra.Command, args []string) error {
+	return config.UseProfile(args[0])
+}
+
+func getProfileCreateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Creates a new configuration profile.",
+		Long:  "Creates a new configuration profile. Values are set with one or more --set key=value flags.",
+		Args:  cobra.ExactArgs(1),
+		RunE:  profileCreateRunE,
+	}
+
+	cmd.Flags().StringToStringVarP(&profileValues, "set", "s", nil, "A configuration property to set in the profile in the form key=value. Can be specified multiple times.")
+
+	return cmd
+}
+
+func profileCreateRunE(cmd *cobra.Command, args []string) error {
+	return config.CreateProfile(args[0], profileValues)
+}
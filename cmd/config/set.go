@@ -1,7 +1,11 @@
 package config
 
 import (
+	"strings"
+
+	"github.com/chelnak/pdk/cmd/completion"
 	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
 	"github.com/spf13/cobra"
 )
 
@@ -20,13 +24,23 @@ func getSetCmd() *cobra.Command {
 
 	cmd.Flags().StringVarP(&key, "key", "k", "", "The configuration property to set.")
 	_ = cmd.MarkFlagRequired("key")
+	_ = cmd.RegisterFlagCompletionFunc("key", completion.ConfigKeys)
 
 	cmd.Flags().StringVarP(&value, "value", "v", "", "The value to set the configuration property to.")
 	_ = cmd.MarkFlagRequired("value")
+	_ = cmd.RegisterFlagCompletionFunc("value", completion.ConfigValues)
 
 	return cmd
 }
 
 func setRunE(cmd *cobra.Command, args []string) error {
+	if !config.IsKey(key) {
+		return pdk_errors.New(pdk_errors.Usage, "invalid configuration key %q. Valid keys are: %s", key, strings.Join(config.Keys(), ", "))
+	}
+
+	if err := config.ValidateValue(key, value); err != nil {
+		return err
+	}
+
 	return config.Set(key, value)
 }
@@ -1,17 +1,18 @@
 package config
 
 import (
-	"errors"
 	"os"
 
 	"github.com/chelnak/pdk/internal/config"
 	"github.com/chelnak/pdk/internal/utils/terminal"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
 	"github.com/spf13/cobra"
 )
 
 var (
 	output  string
 	noColor bool
+	showEnv bool
 )
 
 func getShowCmd() *cobra.Command {
@@ -24,6 +25,7 @@ func getShowCmd() *cobra.Command {
 
 	cmd.Flags().StringVarP(&output, "output", "o", "yaml", "The output format. Valid values are 'json' and 'yaml'. Defaults to 'yaml'.")
 	cmd.Flags().BoolVarP(&noColor, "no-color", "n", false, "Disable color output")
+	cmd.Flags().BoolVarP(&showEnv, "env", "e", false, "List the PDK_ environment variables that are currently overriding the configuration.")
 	return cmd
 }
 
@@ -33,12 +35,16 @@ func showRunE(cmd *cobra.Command, args []string) error {
 		noColor = true
 	}
 
+	if showEnv {
+		return config.PrintEnv(noColor, os.Stdout)
+	}
+
 	switch output {
 	case "json":
 		return config.PrintJSON(noColor, os.Stdout)
 	case "yaml":
 		return config.PrintYAML(noColor, os.Stdout)
 	default:
-		return errors.New("invalid output format. Valid values are 'json' and 'yaml'")
+		return pdk_errors.New(pdk_errors.Usage, "invalid output format. Valid values are 'json' and 'yaml'")
 	}
 }
@@ -14,6 +14,7 @@ func GetContentCmd() *cobra.Command {
 
 	cmd.AddCommand(getNewCmd())
 	cmd.AddCommand(getListCmd())
+	cmd.AddCommand(getUpdateCmd())
 
 	return cmd
 }
@@ -1,6 +1,17 @@
 package content
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/pkg/install"
+	"github.com/chelnak/pdk/pkg/pct_config_processor"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
 
 func getListCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -14,5 +25,28 @@ func getListCmd() *cobra.Command {
 }
 
 func listRunE(cmd *cobra.Command, args []string) error {
-	return nil
+	packages, err := install.List(config.TemplatePath())
+	if err != nil {
+		return err
+	}
+
+	if len(packages) == 0 {
+		fmt.Printf("No templates installed in %s\n", config.TemplatePath())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	processor := &pct_config_processor.PctConfigProcessor{AFS: &afero.Afero{Fs: afero.NewOsFs()}}
+
+	fmt.Fprintln(w, "NAME\tVERSION\tTYPE\tPATH")
+	for _, p := range packages {
+		kind := "unknown"
+		if info, err := processor.ReadConfig(filepath.Join(p.Path, "pct-config.yml")); err == nil {
+			kind = string(info.Template.Kind())
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Name(), p.Version, kind, p.Path)
+	}
+
+	return w.Flush()
 }
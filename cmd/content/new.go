@@ -1,18 +1,334 @@
 package content
 
-import "github.com/spf13/cobra"
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chelnak/pdk/cmd/completion"
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/internal/utils"
+	"github.com/chelnak/pdk/internal/utils/terminal"
+	"github.com/chelnak/pdk/pkg/hooks"
+	"github.com/chelnak/pdk/pkg/parameters"
+	"github.com/chelnak/pdk/pkg/pct_config_processor"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/project"
+	"github.com/chelnak/pdk/pkg/render"
+	"github.com/chelnak/ysmrr"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// moduleMetadata is the file that marks the root of a module.
+const moduleMetadata = "metadata.json"
+
+var (
+	outputDir   string
+	setValues   []string
+	valuesFiles []string
+	trustHooks  bool
+	dryRun      bool
+	showDiff    bool
+	onConflict  string
+	noColor     bool
+)
 
 func getNewCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "new",
+		Use:   "new <template>",
 		Short: "Creates a Puppet project or other artifact based on a template.",
-		Long:  "Creates a Puppet project or other artifact based on a template.",
-		RunE:  newRunE,
+		Long: `Creates a Puppet project or other artifact based on a template.
+
+The template is given as author/id, which selects the highest installed version, or author/id/version.
+
+What is created depends on the type of the template. A project is created in a new directory, which is
+named after the name parameter, or the id of the template if it has none, unless --output is given. The
+directory must be empty unless --on-conflict is given. An item adds files to an existing module, which
+is the current working directory unless --output is given. Tools do not create content. Run them with
+'pdk exec' instead.
+
+Templates can declare parameters. Values are taken from --values files, in the order they are given,
+and then from --set. When running in a terminal, any other parameters are prompted for. Otherwise
+they take their default value.
+
+Templates can also declare hooks, which are commands that run before and after the content is created.
+They run in the output directory with the parameter values in PDK_PARAM_<NAME> environment variables.
+You are asked before any hooks are run. Use --trust-hooks to run them without asking.
+
+Use --dry-run to see the files that would be created without writing anything or running hooks, and
+--diff to see how the template would change files that already exist. --on-conflict decides what
+happens to those files: fail stops before anything is written, skip keeps them, overwrite replaces them
+and prompt asks about each one.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completion.Templates,
+		RunE:              newRunE,
 	}
 
+	cmd.Flags().StringVarP(&outputDir, "output", "o", "", "The directory to create the content in. Defaults to a new directory for projects and the current working directory for items.")
+	cmd.Flags().StringArrayVar(&setValues, "set", nil, "Set a parameter in the form name=value. Can be given more than once.")
+	cmd.Flags().StringArrayVarP(&valuesFiles, "values", "f", nil, "A YAML file of parameter values. Can be given more than once.")
+	cmd.Flags().BoolVar(&trustHooks, "trust-hooks", false, "Run the hooks of the template without asking.")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the files that would be created without writing them.")
+	cmd.Flags().BoolVar(&showDiff, "diff", false, "Show the changes that would be made to files that already exist.")
+	cmd.Flags().StringVar(&onConflict, "on-conflict", string(render.ConflictFail), fmt.Sprintf("What to do with files that already exist with different content. One of %s.", strings.Join(render.ConflictPolicies(), ", ")))
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "Disable color output.")
+	_ = cmd.RegisterFlagCompletionFunc("on-conflict", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return render.ConflictPolicies(), cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = cmd.MarkFlagDirname("output")
+	_ = cmd.MarkFlagFilename("values", "yaml", "yml")
+
 	return cmd
 }
 
 func newRunE(cmd *cobra.Command, args []string) error {
+	policy := render.ConflictPolicy(onConflict)
+	if !utils.Contains(render.ConflictPolicies(), onConflict) {
+		return pdk_errors.New(pdk_errors.Usage, "invalid --on-conflict %q. Use one of %s", onConflict, strings.Join(render.ConflictPolicies(), ", "))
+	}
+
+	if policy == render.ConflictPrompt && !terminal.IsInteractive() && !dryRun {
+		return pdk_errors.New(pdk_errors.Usage, "--on-conflict prompt can only be used in an interactive terminal")
+	}
+
+	if !terminal.IsTTY() {
+		noColor = true
+	}
+
+	pkg, info, err := loadTemplate(args[0])
+	if err != nil {
+		return err
+	}
+
+	kind := info.Template.Kind()
+	if kind == pct_config_processor.Tool {
+		return pdk_errors.New(pdk_errors.Usage, "%s is a tool and does not create content. Run it with 'pdk exec %s'", pkg.Name(), pkg.Name())
+	}
+
+	provided, err := providedValues()
+	if err != nil {
+		return err
+	}
+
+	prompter := newPrompter()
+	values, err := parameters.Resolve(info.Parameters, provided, prompter)
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.Usage, err, "")
+	}
+
+	data := templateData(info, values)
+
+	outputDir, err = targetDir(kind, info.Template.Id, data, cmd.Flags().Changed("on-conflict"))
+	if err != nil {
+		return err
+	}
+
+	renderer := render.NewRenderer()
+	files, err := renderer.Render(pkg.Path, data)
+	if err != nil {
+		return err
+	}
+
+	changes, err := renderer.Plan(outputDir, files)
+	if err != nil {
+		return err
+	}
+
+	if showDiff {
+		if err := printDiffs(changes); err != nil {
+			return err
+		}
+	}
+
+	if dryRun {
+		return printPlan(changes, policy, info.Hooks)
+	}
+
+	err = render.Resolve(changes, policy, func(c render.Change) (bool, error) {
+		return confirm(prompter, fmt.Sprintf("%s already exists. Overwrite it?", c.Path))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := confirmHooks(pkg.Name(), info.Hooks, prompter); err != nil {
+		return err
+	}
+
+	sm := ysmrr.NewSpinnerManager()
+	sm.Start()
+	defer sm.Stop()
+
+	hookOpts := hooks.Options{
+		Dir:     outputDir,
+		Env:     hooks.Env(data),
+		Timeout: config.Timeout(config.Config.HookTimeout),
+	}
+
+	if err := os.MkdirAll(outputDir, 0750); err != nil {
+		return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create %s", outputDir)
+	}
+
+	if err := runHooks(cmd.Context(), sm, info.Hooks.Pre, hookOpts); err != nil {
+		return err
+	}
+
+	spinner := sm.AddSpinner("Creating content...")
+	if _, err := renderer.Write(outputDir, changes); err != nil {
+		spinner.Error()
+		return err
+	}
+
+	// Items are added to a project that has its own state, if any.
+	if kind == pct_config_processor.Project {
+		state := project.State{Template: pkg.Name(), Version: pkg.Version, Parameters: values}
+		if err := project.WriteState(&afero.Afero{Fs: afero.NewOsFs()}, outputDir, state); err != nil {
+			spinner.Error()
+			return err
+		}
+	}
+
+	spinner.UpdateMessage(fmt.Sprintf("Applied %s/%s to %s: %s", pkg.Name(), pkg.Version, outputDir, summary(changes)))
+	spinner.Complete()
+
+	return runHooks(cmd.Context(), sm, info.Hooks.Post, hookOpts)
+}
+
+// targetDir returns the directory that content of the given kind is created
+// in. It defaults to a new directory named after the name parameter or id
+// for projects, which must be empty unless allowExisting is true, and to the
+// current working directory for items, which must be a module.
+func targetDir(kind pct_config_processor.TemplateType, id string, data map[string]interface{}, allowExisting bool) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	afs := &afero.Afero{Fs: afero.NewOsFs()}
+
+	switch kind {
+	case pct_config_processor.Item:
+		dir := outputDir
+		if dir == "" {
+			dir = cwd
+		}
+
+		if ok, _ := afs.Exists(filepath.Join(dir, moduleMetadata)); !ok {
+			return "", pdk_errors.New(pdk_errors.Usage, "%s is not a module. Item templates add files to an existing module, which must have a %s file", dir, moduleMetadata)
+		}
+
+		return dir, nil
+	default:
+		dir := outputDir
+		if dir == "" {
+			name, _ := data["name"].(string)
+			if name == "" {
+				name = id
+			}
+
+			dir = filepath.Join(cwd, name)
+		}
+
+		if empty, err := afs.IsEmpty(dir); err == nil && !empty && !allowExisting {
+			return "", pdk_errors.New(pdk_errors.FileSystem, "%s already exists and is not empty. Use --on-conflict to add the project to it", dir)
+		}
+
+		return dir, nil
+	}
+}
+
+// confirmHooks lists the hooks of the template and asks whether they should
+// be run. It returns an error if they should not.
+func confirmHooks(template string, h hooks.Hooks, prompter parameters.Prompter) error {
+	if h.Len() == 0 || trustHooks {
+		return nil
+	}
+
+	if prompter == nil {
+		return pdk_errors.New(pdk_errors.Hook, "%s runs hooks. Use --trust-hooks to allow them", template)
+	}
+
+	fmt.Printf("%s runs the following commands:\n", template)
+	for _, list := range [][]hooks.Hook{h.Pre, h.Post} {
+		for _, hook := range list {
+			fmt.Printf("  %s\n", hook.CommandLine())
+		}
+	}
+
+	allowed, err := confirm(prompter, "Run these commands?")
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return pdk_errors.New(pdk_errors.Hook, "the hooks of %s were not allowed to run. No content was created", template)
+	}
+
+	return nil
+}
+
+// confirm asks a yes or no question. The answer defaults to no.
+func confirm(prompter parameters.Prompter, question string) (bool, error) {
+	p := parameters.Parameter{Name: "confirm", Type: parameters.Bool, Prompt: question}
+	answer, err := prompter.Ask(p, "no", func(answer string) error {
+		if answer == "" {
+			return nil
+		}
+
+		_, err := p.Coerce(answer)
+		return err
+	})
+	if err != nil || answer == "" {
+		return false, err
+	}
+
+	yes, err := p.Coerce(answer)
+	return yes == true, err
+}
+
+// printPlan prints the files that would be written and the hooks that would
+// run. Conflicts are shown as they would be resolved, unless the policy needs
+// an answer from the user.
+func printPlan(changes []render.Change, policy render.ConflictPolicy, h hooks.Hooks) error {
+	if policy == render.ConflictSkip || policy == render.ConflictOverwrite {
+		if err := render.Resolve(changes, policy, nil); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(render.Tree(outputDir, changes))
+	fmt.Printf("\n%s\n", summary(changes))
+
+	if h.Len() > 0 {
+		fmt.Println("\nHooks that would run:")
+		for _, hook := range h.Pre {
+			fmt.Printf("  before: %s\n", hook.CommandLine())
+		}
+		for _, hook := range h.Post {
+			fmt.Printf("  after:  %s\n", hook.CommandLine())
+		}
+	}
+
+	return nil
+}
+
+// runHooks runs each hook with its own spinner and stops at the first one
+// that fails.
+func runHooks(ctx context.Context, sm ysmrr.SpinnerManager, list []hooks.Hook, opts hooks.Options) error {
+	runner := hooks.NewRunner()
+
+	for _, hook := range list {
+		spinner := sm.AddSpinner(fmt.Sprintf("Running hook: %s", hook.Label()))
+		if err := runner.Run(ctx, hook, opts); err != nil {
+			spinner.Error()
+			return err
+		}
+
+		spinner.Complete()
+	}
+
 	return nil
 }
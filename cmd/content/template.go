@@ -0,0 +1,115 @@
+package content
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/internal/utils/terminal"
+	"github.com/chelnak/pdk/pkg/install"
+	"github.com/chelnak/pdk/pkg/parameters"
+	"github.com/chelnak/pdk/pkg/pct_config_processor"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/project"
+	"github.com/chelnak/pdk/pkg/render"
+	"github.com/spf13/afero"
+)
+
+// loadTemplate finds an installed template by name and reads its config.
+func loadTemplate(name string) (install.InstalledPackage, pct_config_processor.PuppetContentTemplateInfo, error) {
+	var info pct_config_processor.PuppetContentTemplateInfo
+
+	packages, err := install.List(config.TemplatePath())
+	if err != nil {
+		return install.InstalledPackage{}, info, err
+	}
+
+	pkg, ok := install.Find(packages, name)
+	if !ok {
+		return pkg, info, pdk_errors.New(pdk_errors.NotFound, "template %s is not installed. Use 'pdk content list' to see the installed templates", name)
+	}
+
+	processor := &pct_config_processor.PctConfigProcessor{AFS: &afero.Afero{Fs: afero.NewOsFs()}}
+	info, err = processor.ReadConfig(filepath.Join(pkg.Path, "pct-config.yml"))
+	if err != nil {
+		return pkg, info, pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "could not read the config of %s", name)
+	}
+
+	return pkg, info, nil
+}
+
+// templateData returns the data that a template is rendered with.
+func templateData(info pct_config_processor.PuppetContentTemplateInfo, values map[string]interface{}) map[string]interface{} {
+	return parameters.Data(info.Defaults, info.Parameters, values)
+}
+
+// newPrompter returns a prompter when running in a terminal and nil
+// otherwise.
+func newPrompter() parameters.Prompter {
+	if !terminal.IsInteractive() {
+		return nil
+	}
+
+	return parameters.NewPrompter(os.Stdin, os.Stdout)
+}
+
+// providedValues merges the values files and --set values.
+func providedValues() (map[string]interface{}, error) {
+	provided, err := parameters.Provided(valuesFiles, setValues)
+	if err != nil {
+		return nil, pdk_errors.Wrap(pdk_errors.Usage, err, "")
+	}
+
+	return provided, nil
+}
+
+// printDiffs prints a unified diff for each file that the template would
+// change.
+func printDiffs(changes []render.Change) error {
+	for _, c := range changes {
+		diff, err := render.Diff(c)
+		if err != nil {
+			return err
+		}
+
+		if diff == "" {
+			if c.Action == render.Conflict && c.Binary {
+				fmt.Printf("Binary file %s differs\n", c.Path)
+			}
+			continue
+		}
+
+		if err := config.PrintDiff(diff, noColor, os.Stdout); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// summary counts the changes by action, for example "2 create, 1 skip".
+func summary(changes []render.Change) string {
+	counts := map[render.Action]int{}
+	for _, c := range changes {
+		counts[c.Action]++
+	}
+
+	var parts []string
+	for _, action := range []render.Action{
+		render.Create, project.Add, project.Update, project.Merged, render.Overwrite, project.Delete,
+		render.Skip, project.Keep, render.Unchanged, render.Conflict,
+	} {
+		if counts[action] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[action], action))
+		}
+	}
+
+	if len(parts) == 0 {
+		return "no files"
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,204 @@
+package content
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chelnak/pdk/internal/stringutils"
+	"github.com/chelnak/pdk/internal/utils/terminal"
+	"github.com/chelnak/pdk/pkg/merge"
+	"github.com/chelnak/pdk/pkg/parameters"
+	"github.com/chelnak/pdk/pkg/pct_config_processor"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/project"
+	"github.com/chelnak/pdk/pkg/render"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+var updateVersion string
+
+func getUpdateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update [path]",
+		Short: "Applies a newer version of a template to a project that was created from it.",
+		Long: `Applies a newer version of a template to a project that was created from it.
+
+'pdk content new' records the template, its version and the parameter values in .pdk-template.yml.
+update renders both that version and the new one, which defaults to the highest installed version,
+and merges the changes between them in to the files of the project. Both versions must be installed.
+
+Files that were not changed in the project are replaced. Files that were changed in both are merged,
+and regions that were changed in different ways are marked with conflict markers for you to resolve.
+Files that the template removed are deleted unless they were changed in the project.
+
+Parameters that are new in the template version are asked for, or can be given with --set and --values.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: updateRunE,
+	}
+
+	cmd.Flags().StringVar(&updateVersion, "version", "", "The version of the template to update to. Defaults to the highest installed version.")
+	cmd.Flags().StringArrayVar(&setValues, "set", nil, "Set a parameter in the form name=value. Can be given more than once.")
+	cmd.Flags().StringArrayVarP(&valuesFiles, "values", "f", nil, "A YAML file of parameter values. Can be given more than once.")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes that would be made without writing them.")
+	cmd.Flags().BoolVar(&showDiff, "diff", false, "Show the changes that would be made to each file.")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "Disable color output.")
+	_ = cmd.MarkFlagFilename("values", "yaml", "yml")
+
+	return cmd
+}
+
+func updateRunE(cmd *cobra.Command, args []string) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	afs := &afero.Afero{Fs: afero.NewOsFs()}
+	state, err := project.ReadState(afs, dir)
+	if err != nil {
+		return err
+	}
+
+	base, baseInfo, err := loadTemplate(fmt.Sprintf("%s/%s", state.Template, state.Version))
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.KindOf(err), err, "the version that the project was created from is needed to work out what changed")
+	}
+
+	target := state.Template
+	if updateVersion != "" {
+		target = fmt.Sprintf("%s/%s", state.Template, updateVersion)
+	}
+
+	next, nextInfo, err := loadTemplate(target)
+	if err != nil {
+		return err
+	}
+
+	if next.Version == base.Version {
+		fmt.Printf("%s is already at %s/%s\n", dir, next.Name(), next.Version)
+		return nil
+	}
+
+	baseValues, err := parameters.Resolve(baseInfo.Parameters, declared(baseInfo, state.Parameters), nil)
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "the values in %s do not suit %s/%s", project.StateFile, base.Name(), base.Version)
+	}
+
+	provided, err := providedValues()
+	if err != nil {
+		return err
+	}
+
+	nextProvided := declared(nextInfo, state.Parameters)
+	for k, v := range provided {
+		nextProvided[k] = v
+	}
+
+	if !terminal.IsTTY() {
+		noColor = true
+	}
+
+	nextValues, err := parameters.Resolve(nextInfo.Parameters, nextProvided, newPrompter())
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.Usage, err, "")
+	}
+
+	renderer := render.NewRenderer()
+	baseFiles, err := renderer.Render(base.Path, templateData(baseInfo, baseValues))
+	if err != nil {
+		return err
+	}
+
+	nextFiles, err := renderer.Render(next.Path, templateData(nextInfo, nextValues))
+	if err != nil {
+		return err
+	}
+
+	labels := merge.Labels{
+		Ours:   "project",
+		Base:   fmt.Sprintf("%s/%s", base.Name(), base.Version),
+		Theirs: fmt.Sprintf("%s/%s", next.Name(), next.Version),
+	}
+
+	updates, err := project.PlanUpdate(afs, dir, baseFiles, nextFiles, labels)
+	if err != nil {
+		return err
+	}
+
+	changes := make([]render.Change, 0, len(updates))
+	for _, u := range updates {
+		change := render.Change{File: render.File{Path: u.Path, Content: u.Content, Binary: u.Binary}, Action: u.Action}
+		if u.Action != render.Unchanged && u.Action != project.Keep {
+			change.Existing = u.Current
+		}
+
+		changes = append(changes, change)
+	}
+
+	if showDiff {
+		if err := printDiffs(changes); err != nil {
+			return err
+		}
+	}
+
+	if dryRun {
+		var shown []render.Change
+		for _, c := range changes {
+			if c.Action != render.Unchanged {
+				shown = append(shown, c)
+			}
+		}
+
+		fmt.Println(render.Tree(dir, shown))
+		fmt.Printf("\n%s\n", summary(changes))
+		return nil
+	}
+
+	if err := project.ApplyUpdate(afs, dir, updates); err != nil {
+		return err
+	}
+
+	state.Version = next.Version
+	state.Parameters = nextValues
+	if err := project.WriteState(afs, dir, state); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s from %s to %s: %s\n", dir, labels.Base, labels.Theirs, summary(changes))
+
+	var conflicts []string
+	for _, u := range updates {
+		if u.Action == render.Conflict {
+			conflicts = append(conflicts, u.Path)
+			if u.Binary {
+				fmt.Printf("  %s (binary, kept the project version)\n", u.Path)
+			} else {
+				fmt.Printf("  %s (%d %s)\n", u.Path, u.Conflicts, stringutils.Pluralize(u.Conflicts, "conflict"))
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return pdk_errors.New(pdk_errors.MergeConflict, "%d %s could not be merged cleanly", len(conflicts), stringutils.Pluralize(len(conflicts), "file"))
+	}
+
+	return nil
+}
+
+// declared returns the values for the parameters that info declares. Values
+// for parameters that a template version no longer has are dropped.
+func declared(info pct_config_processor.PuppetContentTemplateInfo, values map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, p := range info.Parameters {
+		if v, ok := values[p.Name]; ok {
+			result[p.Name] = v
+		}
+	}
+
+	return result
+}
@@ -2,21 +2,111 @@
 // using the configured backend.
 package exec
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chelnak/pdk/cmd/completion"
+	"github.com/chelnak/pdk/internal/tools"
+	"github.com/chelnak/pdk/internal/utils"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/tool"
+	"github.com/spf13/cobra"
+)
+
+var (
+	capability string
+	showInfo   bool
+)
 
 // GetExecCmd returns a cobra.Command that implements functionality fpr executing a
 // tool against some Puppet content.
 func GetExecCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "exec",
+		Use:   "exec <tool> [-- args...]",
 		Short: "Executes a given tool against some Puppet Content.",
-		Long:  "Executes a given tool against some Puppet Content.",
-		RunE:  execRunE,
+		Long: `Executes a given tool against some Puppet Content.
+
+The tool is given as author/id, which selects the highest installed version, or author/id/version, and
+must be installed in the configured tool_path. It runs in code_dir, or the current working directory if
+that is not set, with its output shown as it is written. Tools with an image run in a container and
+need the docker backend.
+
+--capability adds the arguments that the tool declares for validate, test or format. Arguments after
+-- are passed to the tool last, after those in tool_args. The tool may run for tool_timeout seconds.
+
+Use --info to see how the tool runs and what it provides without running it.`,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completion.Tools,
+		RunE:              execRunE,
 	}
 
+	cmd.Flags().StringVar(&capability, "capability", "", fmt.Sprintf("Run the tool for one of its capabilities. One of %s.", strings.Join(tool.Capabilities(), ", ")))
+	cmd.Flags().BoolVar(&showInfo, "info", false, "Show how the tool runs and what it provides without running it.")
+	_ = cmd.RegisterFlagCompletionFunc("capability", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return tool.Capabilities(), cobra.ShellCompDirectiveNoFileComp
+	})
+
 	return cmd
 }
 
 func execRunE(cmd *cobra.Command, args []string) error {
-	return nil
+	if capability != "" && !utils.Contains(tool.Capabilities(), capability) {
+		return pdk_errors.New(pdk_errors.Usage, "invalid --capability %q. Use one of %s", capability, strings.Join(tool.Capabilities(), ", "))
+	}
+
+	t, err := tools.Find(args[0])
+	if err != nil {
+		return err
+	}
+
+	if showInfo {
+		return printInfo(t)
+	}
+
+	_, err = tools.Run(cmd.Context(), t, tool.Capability(capability), args[1:], os.Stdout, os.Stderr)
+	return err
+}
+
+// printInfo describes the tool section of t.
+func printInfo(t tools.Installed) error {
+	spec := t.Info.Tool
+
+	runs := fmt.Sprintf("%s (%s backend)", spec.Binary, tool.Local)
+	if spec.Image != "" {
+		runs = fmt.Sprintf("%s (%s backend)", spec.Image, tool.Docker)
+		if spec.Entrypoint != "" {
+			runs = fmt.Sprintf("%s with entrypoint %s", runs, spec.Entrypoint)
+		}
+	}
+
+	versions := "any"
+	if len(spec.PuppetVersions) > 0 {
+		versions = strings.Join(spec.PuppetVersions, ", ")
+	}
+
+	output := "shown as it is"
+	if spec.Output.Parses() {
+		stream := spec.Output.Stream
+		if stream == "" {
+			stream = "stdout"
+		}
+		output = fmt.Sprintf("%s results on %s", spec.Output.Format, stream)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", t.Name())
+	fmt.Fprintf(w, "Version:\t%s\n", t.Version)
+	fmt.Fprintf(w, "Path:\t%s\n", t.Path)
+	fmt.Fprintf(w, "Runs:\t%s\n", runs)
+	fmt.Fprintf(w, "Args:\t%s\n", strings.Join(spec.Args, " "))
+	fmt.Fprintf(w, "Puppet versions:\t%s\n", versions)
+	for _, name := range spec.Provides() {
+		fmt.Fprintf(w, "Capability %s:\t%s\n", name, strings.Join(spec.Capabilities[name].Args, " "))
+	}
+	fmt.Fprintf(w, "Output:\t%s\n", output)
+
+	return w.Flush()
 }
@@ -2,21 +2,59 @@
 // commands provided by PDK.
 package explain
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/spf13/cobra"
+)
 
 // GetExplainCmd returns a cobra.Command that implements functionality
 // for explaining functionality of the cli. Think of it as advanced help.
 func GetExplainCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "explain",
-		Short: "Present documentation about topics.",
-		Long:  "Present documentation about topics.",
-		RunE:  explainRunE,
+		Use:               "explain [topic]",
+		Short:             "Present documentation about topics.",
+		Long:              "Present documentation about topics. Run without a topic to list all available topics.",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: topicsCompletion,
+		RunE:              explainRunE,
 	}
 
 	return cmd
 }
 
 func explainRunE(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		fmt.Println("Available topics:")
+		fmt.Println()
+		for _, name := range topicNames() {
+			fmt.Printf("  %-12s %s\n", name, topics[name].summary)
+		}
+		return nil
+	}
+
+	t, ok := topics[args[0]]
+	if !ok {
+		return pdk_errors.New(pdk_errors.Usage, "unknown topic %q. Valid topics are: %s", args[0], strings.Join(topicNames(), ", "))
+	}
+
+	fmt.Printf("%s\n\n%s\n", t.summary, t.body)
 	return nil
 }
+
+func topicsCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, name := range topicNames() {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, fmt.Sprintf("%s\t%s", name, topics[name].summary))
+		}
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
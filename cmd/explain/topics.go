@@ -0,0 +1,316 @@
+package explain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+)
+
+// topic is a piece of documentation that can be presented by the explain
+// command.
+type topic struct {
+	summary string
+	body    string
+}
+
+var topics = map[string]topic{
+	"config": {
+		summary: "How the pdk is configured.",
+		body: `The pdk reads its configuration from $HOME/.config/puppetlabs/pdk/.pdk.yaml. A different file can be
+used with the --config flag. The file is created with default values the first time the pdk runs.
+
+Use 'pdk config show' to see the current configuration, 'pdk config set' to change a single value and
+'pdk config edit' to open the file in your editor.
+
+The install_timeout and build_timeout keys limit how long 'pdk install' and 'pdk build' may run, in
+seconds. hook_timeout limits each template hook in the same way. A value of 0 disables the timeout.`,
+	},
+	"environment": {
+		summary: "Overriding configuration with environment variables.",
+		body: `Every configuration key can be overridden with an environment variable. The name of the variable is
+the key in upper case, prefixed with PDK_. For example PDK_BACKEND overrides backend.
+
+Use 'pdk config show --env' to list the variables that are currently in effect.`,
+	},
+	"offline": {
+		summary: "Running without network access.",
+		body: `Offline mode guarantees that the pdk makes no network connections, which is needed on air-gapped
+build agents. Enable it for a single command with --offline, or for every command with the offline key:
+
+  pdk config set offline true
+
+PDK_OFFLINE=true works too. In offline mode 'pdk install' only accepts local tar.gz files and local git
+repositories. URLs and remote git repositories fail straight away with error PDK404.
+
+Offline mode is enforced by the HTTP transport and the git fetcher rather than by each command. Tools
+with an image are started with 'docker run --pull never', so an image that is not already loaded fails
+with error PDK404 instead of being pulled.
+
+To move templates, tools and runtime images to an air-gapped machine, run 'pdk bundle export' on a
+connected machine and 'pdk bundle import' on the air-gapped one. Imports verify the checksum of
+everything in the bundle before installing it.`,
+	},
+	"profiles": {
+		summary: "Switching between sets of configuration values.",
+		body: `Profiles are named sets of configuration values stored under the profiles key of the config file.
+The values of the selected profile are applied on top of the rest of the configuration.
+
+A profile is selected with the --profile flag, the PDK_PROFILE environment variable or the default set
+with 'pdk config profile use'. Profiles are created with 'pdk config profile create'.`,
+	},
+	"install": {
+		summary: "Installing templates and tools.",
+		body: `Packages are installed with 'pdk install --source'. The source can be a local tar.gz file, a URL to a
+tar.gz file or a git repository.
+
+Packages are installed to the template path in an author/id/version layout. Use --force to replace a
+version that is already installed.
+
+Git repositories are fetched without needing git to be installed. Use --ref to install a branch, tag or
+commit. SSH URLs authenticate with the keys held by your ssh-agent and HTTPS URLs use the credentials
+configured for the host. See 'pdk explain credentials'.
+
+Downloads that fail with a network error or a 429 or 5xx response are retried download_retries times
+with an increasing delay, resuming from where the previous attempt stopped when the server supports it.
+Packages larger than download_max_size megabytes are rejected.`,
+	},
+	"network": {
+		summary: "Proxies, custom CAs and client certificates.",
+		body: `Downloads and git fetches over HTTPS use the proxy set in the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+environment variables. Connections to localhost are never proxied.
+
+Proxies that intercept TLS present certificates signed by their own CA. Set ca_file to a PEM bundle
+holding that CA and it is trusted in addition to the system roots:
+
+  pdk config set ca_file /etc/pki/corp-ca.pem
+
+Servers that require a client certificate are sent the certificate and key in client_cert and
+client_key. Both must be PEM files.
+
+Use 'pdk runtime status' to check the proxy and TLS settings. It connects to github.com and to every
+host with credentials, and explains any certificate that is not trusted. Use --host to check other
+hosts.`,
+	},
+	"errors": {
+		summary: "Error codes and exit codes.",
+		body:    errorsBody(),
+	},
+	"credentials": {
+		summary: "Downloading packages from private hosts.",
+		body: `Credentials for private hosts, such as Artifactory or a private GitHub, are configured in the
+credentials list of the config file:
+
+  credentials:
+    - host: artifactory.example.com
+      type: bearer
+      token_env: ARTIFACTORY_TOKEN
+    - host: github.example.com
+      type: basic
+      username: deploy
+      password_env: GITHUB_TOKEN
+
+Valid types are bearer, basic and netrc. Secrets can be stored in the file with token and password, or
+read from environment variables named by token_env and password_env. Hosts without credentials fall
+back to the netrc file at $NETRC or ~/.netrc. Credentials are also used for git repositories fetched
+over HTTPS.
+
+Credentials are only sent over HTTPS. When a server redirects a download to another host, that host
+only gets the credentials configured for it. The default entry of the netrc file is not used for
+redirects.
+
+Secrets are never printed by 'pdk config show' or written to debug logs.`,
+	},
+	"authoring": {
+		summary: "Creating and testing templates.",
+		body: `'pdk template init' creates a new template project with a pct-config.yml, sample content that uses
+parameters, helpers and a partial, a README, a .pdkignore and a test fixture.
+
+The type in the template section of pct-config.yml says what kind of package it is:
+
+  project  creates a new project in a directory of its own. This is the default.
+  item     adds files to an existing module, such as a class or a defined type.
+  tool     is run against content by 'pdk exec' and 'pdk validate'. See 'pdk explain tools'.
+
+Projects and items need a content directory. Tools need a tool section instead, and can not have
+parameters or hooks.
+
+Fixtures live in the tests directory. Each one is a directory with a values.yml file of parameter values
+and an expected directory with the output that the template should render with them:
+
+  tests/
+    default/
+      values.yml
+      expected/
+        manifests/init.pp
+
+'pdk template test' renders every fixture and reports files that are missing, unexpected or different,
+with a diff of each difference. It fails with error PDK203 if any fixture does not match. After changing
+the template on purpose, run 'pdk template test --update' to replace the expected output and review the
+changes before committing them.
+
+'pdk build' leaves out the files that match the patterns in .pdkignore, which uses the same syntax as
+.gitignore. The generated .pdkignore leaves out tests, so fixtures are not installed with the template.`,
+	},
+	"build": {
+		summary: "Building template packages.",
+		body: `'pdk build' packages a template project in to a tar.gz file that can be installed with 'pdk install'.
+
+A template project must contain a pct-config.yml file with an id, author and version. Projects and
+items also need a content directory, and tools need a tool section. See 'pdk explain authoring'.
+
+pct-config.yml is checked against a JSON Schema that covers the template block, defaults, parameters
+and dependencies. Run 'pdk template lint' to check it on its own. Problems are reported with the line and
+column that they were found at. 'pdk template lint --schema' prints the schema for use in your editor.
+
+The version must be a full semantic version such as "1.0.0". Templates with a short version such as
+0.1 or 1.0 were accepted before the schema was introduced and must be updated.
+
+The names and contents of the files in content are Go templates. Names such as manifests/{{.name}}.pp
+are rendered with the parameter values, and files or directories whose name renders to nothing are left
+out. A .tmpl suffix is removed from the rendered name. Binary files are copied as they are.
+
+Files in the partials directory can be used from any file with {{template "name" .}}, or with
+{{include "name" .}} when the result needs to go through another helper. As well as the text/template
+builtins, templates can use lower, upper, title, snake, kebab, camel, pascal, className, isClassName,
+plural, pluralize, trim, replace, join, quote, indent and default.
+
+Every declared parameter can be used in a template, even when it has no value. Any other key that is
+missing from the data is an error, so read keys that may not be set with index, as in
+{{index . "owner" | default "puppet"}}.`,
+	},
+	"hooks": {
+		summary: "Commands that templates run when content is created.",
+		body: `Templates can declare hooks in pct-config.yml to run commands before and after 'pdk content new'
+writes its files:
+
+  hooks:
+    pre:
+      - name: Check for git
+        command: git
+        args: [--version]
+    post:
+      - name: Initialise a repository
+        command: git
+        args: [init]
+
+Pre hooks run before anything is written and post hooks run afterwards. Both run in the output directory
+with each parameter value in an environment variable named PDK_PARAM_<NAME>. Lists are comma separated.
+
+Hooks run arbitrary commands, so the pdk lists them and asks before running them. Use --trust-hooks to
+run them without asking, which is needed when the pdk is not running in a terminal. Each hook may run for
+hook_timeout seconds. The first hook that fails stops the command with error PDK201.`,
+	},
+	"tools": {
+		summary: "Running tools against content.",
+		body: `A tool is a package with the type tool in pct-config.yml and a tool section that says how to run it:
+
+  template:
+    id: lint
+    author: me
+    version: 0.1.0
+    type: tool
+  tool:
+    binary: bin/lint              # or image: example/lint:1.0, with an optional entrypoint
+    args: [--relative]
+    puppet_versions: ["7", "8"]
+    capabilities:
+      validate:
+        args: [check]
+      format:
+        args: [fix]
+    output:
+      format: text
+      pattern: '^(?P<file>[^:]+):(?P<line>[0-9]+): (?P<severity>[a-z]+): (?P<message>.*)$'
+
+A binary such as bin/lint is relative to the package and a bare name is looked up on the PATH. Paths
+must stay inside the package, so absolute paths and paths with .. are rejected. The same applies to an
+entrypoint. Tools with an image run in a container with docker and need the docker backend. The content is mounted at
+/code and the package at /tool. puppet_versions limits the values of puppet_version that the tool can
+be used with. A version such as 7 matches every 7.x release.
+
+Tools are built with 'pdk build' and 'pdk install' puts them in tool_path. 'pdk exec author/id' runs a
+tool in code_dir, or the current working directory if it is not set. Its args come first, then those of
+the capability given with --capability, then tool_args from the config and then any arguments given
+after --. The package directory is in PDK_TOOL_DIR and puppet_version in PDK_PUPPET_VERSION. A tool may
+run for tool_timeout seconds. 'pdk exec --info' shows how a tool runs and what it provides.
+
+'pdk validate' runs every installed tool with the validate capability, or the ones that are given. The
+output of tools with an output section is turned in to results: text output is matched line by line
+against pattern, whose named groups are file, line, column, severity, rule and message, and json output
+must be an array of objects with those fields. Results are listed, or written to pdk-results.json when
+results_view is file. A tool that exits with a code other than zero fails the command with error
+PDK600, and a tool that can not be used with the configuration fails it with PDK601.
+
+testdata/tools/stub in the pdk repository is a tool that only needs a shell, for trying this out.`,
+	},
+	"update": {
+		summary: "Applying new versions of a template to a project.",
+		body: `'pdk content new' writes .pdk-template.yml to the root of the project. It records the template, the
+version that was used and the parameter values. Keep it in version control.
+
+'pdk content update' renders the recorded version and the new version with those values and applies
+the difference to the project with a three-way merge:
+
+  • files that the project did not change are replaced with the new version
+  • changes made on both sides are combined when they touch different lines
+  • lines that were changed in different ways are left between conflict markers
+  • files that the template no longer has are deleted unless the project changed them
+
+Both versions of the template must be installed. Use --dry-run to see what would change and --diff to
+see the changes to each file. When there are conflicts the command fails with error PDK202 after
+writing everything else. Resolve the markers by hand, as you would for a git merge.`,
+	},
+	"parameters": {
+		summary: "Declaring and setting template parameters.",
+		body: `Templates declare the values that they need in the parameters section of pct-config.yml:
+
+  parameters:
+    name:
+      description: The name of the module
+      pattern: "^[a-z][a-z0-9_]*$"
+      required: true
+    license:
+      type: enum
+      choices: [MIT, Apache-2.0]
+      default: MIT
+    owner:
+      when: "!private"
+
+The type is one of string, int, bool, enum or list and defaults to string. Ints can be limited with min
+and max, and strings and list items with pattern, which must match the whole value. when makes a
+parameter depend on another one and takes a name, a name prefixed with !, or a comparison such as
+license == MIT. Values given for a parameter whose when condition is not met are ignored.
+
+'pdk content new' takes values from --values files and then from --set name=value. Lists are given as
+comma separated values. In a terminal, the remaining parameters are prompted for. Otherwise they take
+their default, and required parameters without a default are an error.`,
+	},
+}
+
+func errorsBody() string {
+	var b strings.Builder
+	b.WriteString(`Every error reported by the pdk has a stable code and the pdk exits with an exit code that is
+specific to the kind of error. Use --error-format json to report errors in a format that can be parsed.
+
+`)
+
+	fmt.Fprintf(&b, "  %-7s %-5s %s\n", "CODE", "EXIT", "HINT")
+	for _, kind := range pdk_errors.Catalogue() {
+		fmt.Fprintf(&b, "  %-7s %-5d %s\n", kind.Code, kind.ExitCode, kind.Hint)
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// topicNames returns the names of all topics in alphabetical order.
+func topicNames() []string {
+	names := make([]string, 0, len(topics))
+	for name := range topics {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
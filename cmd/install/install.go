@@ -2,12 +2,15 @@
 package install
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 
+	"github.com/chelnak/pdk/internal/config"
 	"github.com/chelnak/pdk/internal/stringutils"
 	"github.com/chelnak/pdk/pkg/install"
+	"github.com/chelnak/pdk/pkg/network"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
 	"github.com/chelnak/ysmrr"
 	"github.com/spf13/cobra"
 )
@@ -15,16 +18,24 @@ import (
 var (
 	source string
 	target string
+	ref    string
 	force  bool
+
+	// toolPath is where tools are installed. It is only set when --target
+	// is not given.
+	toolPath string
 )
 
 // GetInstallCmd returns a cobra.Command that implements functionality
 // for installing a template package.
 func GetInstallCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "install",
-		Short:   "Installs a template package in tar.gz format or from a git repository.",
-		Long:    "Installs a template package in tar.gz format or from a git repository.",
+		Use:   "install",
+		Short: "Installs a template package in tar.gz format or from a git repository.",
+		Long: `Installs a template package in tar.gz format or from a git repository.
+
+Templates are installed in the configured template_path and tools in the configured tool_path, unless
+--target is given.`,
 		PreRunE: installPreRunE,
 		RunE:    installRunE,
 	}
@@ -32,23 +43,21 @@ func GetInstallCmd() *cobra.Command {
 	cmd.Flags().StringVarP(&source, "source", "s", "", "The path of the template package.")
 	_ = cmd.MarkFlagRequired("source")
 
-	cmd.Flags().StringVarP(&target, "target", "t", "", "The directory where the template package will be installed.")
+	cmd.Flags().StringVarP(&target, "target", "t", "", "The directory where the package will be installed. Defaults to the configured template_path, or tool_path for tools.")
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force the installation of the template package.")
+	cmd.Flags().StringVarP(&ref, "ref", "r", "", "The branch, tag or commit to install when the source is a git repository.")
 
 	return cmd
 }
 
 func installPreRunE(cmd *cobra.Command, args []string) error {
-	wd, err := os.Getwd()
-
-	if target == "" && err != nil {
-		return err
-	}
-
 	if target == "" {
-		target = filepath.Clean(wd)
+		target = config.TemplatePath()
+		toolPath = config.ToolPath()
 	}
 
+	target = filepath.Clean(target)
+
 	return nil
 }
 
@@ -59,17 +68,41 @@ func installRunE(cmd *cobra.Command, args []string) error {
 	sm.Start()
 	defer sm.Stop()
 
-	installer := install.NewInstaller()
+	ctx := cmd.Context()
+	if timeout := config.Timeout(config.Config.InstallTimeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	transport, err := network.NewTransport(config.NetworkOptions())
+	if err != nil {
+		spinner.Error()
+		return err
+	}
+
+	installer := install.NewInstaller(install.Options{
+		Credentials:     config.CredentialStore(),
+		Transport:       transport,
+		Retries:         config.Config.DownloadRetries,
+		MaxDownloadSize: int64(config.Config.DownloadMaxSize) * 1024 * 1024,
+		Progress: func(downloaded, total int64) {
+			spinner.UpdateMessage(downloadMessage(downloaded, total))
+		},
+		ToolPath: toolPath,
+	})
 
 	var i string
-	var err error
 	if stringutils.IsGitURL(source) {
-		i, err = installer.InstallClone(source, target, force)
-	} else if stringutils.IsTarGZ(source) {
-		i, err = installer.Install(source, target, force)
+		if ref != "" {
+			source = fmt.Sprintf("%s#%s", source, ref)
+		}
+		i, err = installer.InstallClone(ctx, source, target, force)
+	} else if stringutils.IsTarGZ(source) || stringutils.IsHTTPURL(source) {
+		i, err = installer.Install(ctx, source, target, force)
 	} else {
 		spinner.Error()
-		return fmt.Errorf("invalid source path: %s", source)
+		return pdk_errors.New(pdk_errors.Usage, "invalid source path: %s", source)
 	}
 
 	if err != nil {
@@ -82,3 +115,26 @@ func installRunE(cmd *cobra.Command, args []string) error {
 	spinner.Complete()
 	return nil
 }
+
+func downloadMessage(downloaded, total int64) string {
+	if total <= 0 {
+		return fmt.Sprintf("Downloading package... %s", formatBytes(downloaded))
+	}
+
+	return fmt.Sprintf("Downloading package... %s / %s (%d%%)", formatBytes(downloaded), formatBytes(total), downloaded*100/total)
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
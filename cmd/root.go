@@ -3,25 +3,40 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/chelnak/pdk/cmd/build"
+	"github.com/chelnak/pdk/cmd/bundle"
+	"github.com/chelnak/pdk/cmd/completion"
 	"github.com/chelnak/pdk/cmd/config"
 	"github.com/chelnak/pdk/cmd/content"
 	"github.com/chelnak/pdk/cmd/exec"
 	"github.com/chelnak/pdk/cmd/explain"
 	"github.com/chelnak/pdk/cmd/install"
 	"github.com/chelnak/pdk/cmd/runtime"
+	"github.com/chelnak/pdk/cmd/template"
 	"github.com/chelnak/pdk/cmd/validate"
 	appConfig "github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/internal/logging"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
 	"github.com/spf13/cobra"
 )
 
 var (
-	errSilent  = errors.New("ErrSilent")
-	configFile string
+	errSilent   = errors.New("ErrSilent")
+	configFile  string
+	profile     string
+	debug       bool
+	logFormat   string
+	logFile     string
+	errorFormat string
+	offline     bool
 )
 
 func getRootCmd() *cobra.Command {
@@ -37,29 +52,90 @@ PDK includes testing tools, a complete module skeleton, and command line tools t
 		SilenceErrors:     true,
 		SilenceUsage:      true,
 		PersistentPreRunE: rootPersistentPreRunE,
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
 	}
 
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "Path to a config file. This will override the default config file located at $HOME/.config/puppetlabs/pdk/.pdk.yaml.")
 	_ = rootCmd.MarkFlagFilename("config", "yaml", "yml")
 
-	rootCmd.PersistentFlags().BoolP("debug", "d", false, "Enable debug mode.")
+	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", "", "The name of a configuration profile to use. This will override the PDK_PROFILE environment variable and the default profile.")
+
+	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Enable debug mode.")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "The format of log output. Valid values are 'text' and 'json'.")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Path to a file that debug logs will be appended to instead of stderr.")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Disable all network access. Packages can only be installed from local files.")
+	rootCmd.PersistentFlags().StringVar(&errorFormat, "error-format", "text", "The format of error output. Valid values are 'text' and 'json'.")
 
 	return rootCmd
 }
 
 func rootPersistentPreRunE(cmd *cobra.Command, args []string) error {
-	return appConfig.InitConfig(configFile)
+	err := logging.Init(logging.Options{
+		Debug:  debug,
+		Format: logFormat,
+		File:   logFile,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, skipMigration := cmd.Annotations[appConfig.SkipMigrationAnnotation]
+
+	return appConfig.InitConfig(appConfig.InitOptions{
+		File:          configFile,
+		Profile:       profile,
+		SkipMigration: skipMigration,
+		Offline:       offline,
+	})
+}
+
+// errorReport is the format that errors are written in when --error-format is
+// set to json.
+type errorReport struct {
+	Error struct {
+		pdk_errors.Kind
+		Message     string `json:"message"`
+		Remediation string `json:"remediation,omitempty"`
+	} `json:"error"`
 }
 
 func formatError(err error) {
+	kind := pdk_errors.KindOf(err)
+
+	if errorFormat == "json" {
+		var report errorReport
+		report.Error.Kind = kind
+		report.Error.Message = err.Error()
+		report.Error.Remediation = kind.Remediation()
+
+		b, _ := json.Marshal(report)
+		fmt.Fprintln(os.Stderr, string(b))
+		return
+	}
+
 	fmt.Println("\n❌ It looks like something went wrong!\n\nFor more details try running the command again with --debug.")
 	fmt.Println("\nReported errors:")
-	fmt.Fprintln(os.Stderr, fmt.Errorf("• %s", err))
+	fmt.Fprintln(os.Stderr, fmt.Errorf("• [%s] %s", kind.Code, err))
+	fmt.Printf("\n%s\n", kind.Remediation())
 	fmt.Println()
 }
 
 // Execute is the entrypoint for the cli. It is called directly from main.
+// SIGINT and SIGTERM cancel the context passed to commands so that they can
+// stop and clean up after themselves.
 func Execute() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Restore the default behaviour once a signal has been received so that
+	// a second signal terminates the process immediately.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	rootCmd := getRootCmd()
 
 	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
@@ -71,18 +147,26 @@ func Execute() int {
 	rootCmd.AddCommand(content.GetContentCmd())
 	rootCmd.AddCommand(build.GetBuildCmd())
 	rootCmd.AddCommand(install.GetInstallCmd())
+	rootCmd.AddCommand(bundle.GetBundleCmd())
 	rootCmd.AddCommand(exec.GetExecCmd())
 	rootCmd.AddCommand(validate.GetValidateCmd())
 	rootCmd.AddCommand(runtime.GetRuntimeCmd())
+	rootCmd.AddCommand(template.GetTemplateCmd())
 	rootCmd.AddCommand(explain.GetExplainCmd())
 	rootCmd.AddCommand(config.GetConfigCmd())
+	rootCmd.AddCommand(completion.GetCompletionCmd())
+
+	defer func() {
+		_ = logging.Close()
+	}()
 
-	if err := rootCmd.Execute(); err != nil {
-		if err != errSilent {
-			formatError(err)
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		if err == errSilent {
+			return pdk_errors.Usage.ExitCode
 		}
 
-		return 1
+		formatError(err)
+		return pdk_errors.KindOf(err).ExitCode
 	}
 
 	return 0
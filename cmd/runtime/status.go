@@ -1,18 +1,164 @@
 package runtime
 
-import "github.com/spf13/cobra"
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/pkg/network"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/spf13/cobra"
+)
+
+const checkTimeout = 10 * time.Second
+
+var hosts []string
 
 func getStatusCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Shows the status of the runtime.",
-		Long:  "Shows the status of the runtime.",
-		RunE:  statusRunE,
+		Long: `Shows the status of the runtime.
+
+The proxy settings and TLS configuration are checked by connecting to github.com and to every host that
+has credentials configured. Use --host to check other hosts.`,
+		RunE: statusRunE,
 	}
 
+	cmd.Flags().StringSliceVar(&hosts, "host", nil, "A host to check a TLS connection to. Can be given more than once.")
+
 	return cmd
 }
 
+// check is the outcome of a single status check.
+type check struct {
+	name   string
+	err    error
+	detail string
+}
+
 func statusRunE(cmd *cobra.Command, args []string) error {
+	var checks []check
+
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
+		checks = append(checks, check{name: name, detail: proxyEnv(name)})
+	}
+
+	opts := config.NetworkOptions()
+
+	var caErr error
+	if opts.CAFile == "" {
+		checks = append(checks, check{name: "ca_file", detail: "not configured, using system roots"})
+	} else {
+		var count int
+		count, caErr = network.CACertificates(opts.CAFile)
+		checks = append(checks, check{name: "ca_file", err: caErr, detail: fmt.Sprintf("%s (certificates: %d)", opts.CAFile, count)})
+	}
+
+	if caErr != nil {
+		return printChecks(checks)
+	}
+
+	transport, err := network.NewTransport(opts)
+	if opts.ClientCert == "" && opts.ClientKey == "" {
+		checks = append(checks, check{name: "client_cert", detail: "not configured"})
+	} else {
+		checks = append(checks, check{name: "client_cert", err: err, detail: opts.ClientCert})
+	}
+
+	if err != nil {
+		return printChecks(checks)
+	}
+
+	if network.Offline() {
+		checks = append(checks, check{name: "hosts", detail: "not checked in offline mode"})
+		return printChecks(checks)
+	}
+
+	for _, host := range checkHosts() {
+		c := check{name: host, detail: "direct"}
+		if proxy, err := network.ProxyFor(transport, "https://"+host+"/"); err == nil && proxy != nil {
+			c.detail = "via " + proxy.Redacted()
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), checkTimeout)
+		c.err = network.CheckHost(ctx, transport, host)
+		cancel()
+
+		checks = append(checks, c)
+	}
+
+	return printChecks(checks)
+}
+
+// checkHosts returns the hosts to check a TLS connection to.
+func checkHosts() []string {
+	if len(hosts) > 0 {
+		return hosts
+	}
+
+	result := []string{"github.com"}
+	for _, c := range config.Config.Credentials {
+		if c.Host != "github.com" {
+			result = append(result, c.Host)
+		}
+	}
+
+	return result
+}
+
+// proxyEnv returns the value of the proxy environment variable name, or its
+// lower case form, with any password removed.
+func proxyEnv(name string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		value = os.Getenv(strings.ToLower(name))
+	}
+
+	if value == "" {
+		return "not set"
+	}
+
+	if u, err := url.Parse(value); err == nil && u.Host != "" {
+		return u.Redacted()
+	}
+
+	return value
+}
+
+func printChecks(checks []check) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+
+	var failed []check
+	for _, c := range checks {
+		status := "ok"
+		detail := c.detail
+		if c.err != nil {
+			status = "failed"
+			if detail == "" {
+				detail = c.err.Error()
+			} else {
+				detail = fmt.Sprintf("%s: %v", detail, c.err)
+			}
+			failed = append(failed, c)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.name, status, detail)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return pdk_errors.Wrap(pdk_errors.KindOf(failed[0].err), failed[0].err, "%d of %d checks failed", len(failed), len(checks))
+	}
+
 	return nil
 }
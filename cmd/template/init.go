@@ -0,0 +1,135 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+
+	"github.com/chelnak/pdk/internal/utils/terminal"
+	"github.com/chelnak/pdk/pkg/fixture"
+	"github.com/chelnak/pdk/pkg/parameters"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/scaffold"
+	"github.com/spf13/cobra"
+)
+
+// namePattern is what the schema allows for the id and author of a
+// template.
+const namePattern = "^[a-zA-Z0-9][a-zA-Z0-9_-]*$"
+
+var (
+	initID      string
+	initAuthor  string
+	initDisplay string
+)
+
+func getInitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init [path]",
+		Short: "Creates a new template project.",
+		Long: `Creates a new template project.
+
+The project is created in path, which defaults to the current working directory, and contains:
+
+  • a pct-config.yml with an example of each section, including parameters
+  • sample content that uses the parameters, helpers and a partial
+  • a README and a .pdkignore that keeps the tests out of the package
+  • a test fixture in tests/default for 'pdk template test', with its expected output
+
+In a terminal, the id, author and display name are asked for. Otherwise they are taken from --id,
+--author and --display, or default to the name of the directory and the current user.`,
+		Args: cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		},
+		RunE: initRunE,
+	}
+
+	cmd.Flags().StringVar(&initID, "id", "", "The id of the template. Defaults to the name of the directory.")
+	cmd.Flags().StringVar(&initAuthor, "author", "", "The author of the template. Defaults to the current user.")
+	cmd.Flags().StringVar(&initDisplay, "display", "", "A human readable name for the template.")
+
+	return cmd
+}
+
+func initRunE(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	provided := map[string]interface{}{}
+	for name, value := range map[string]string{"id": initID, "author": initAuthor, "display": initDisplay} {
+		if value != "" {
+			provided[name] = value
+		}
+	}
+
+	var prompter parameters.Prompter
+	if terminal.IsInteractive() {
+		prompter = parameters.NewPrompter(os.Stdin, os.Stdout)
+	}
+
+	values, err := parameters.Resolve(initParameters(filepath.Base(abs)), provided, prompter)
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.Usage, err, "")
+	}
+
+	opts := scaffold.Options{
+		ID:      values["id"].(string),
+		Author:  values["author"].(string),
+		Display: values["display"].(string),
+	}
+
+	if opts.Display == "" {
+		opts.Display = opts.ID
+	}
+
+	written, err := scaffold.NewScaffolder().Create(dir, opts)
+	if err != nil {
+		return err
+	}
+
+	runner := fixture.NewRunner()
+	fixtures, err := runner.List(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range fixtures {
+		if _, err := runner.Run(dir, name, true); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Created %s/%s in %s with %d files\n", opts.Author, opts.ID, dir, len(written))
+	fmt.Println("Run 'pdk template test' to check the template and 'pdk build' to package it.")
+	return nil
+}
+
+// initParameters describes the values that init asks for. The id defaults
+// to dir when it is a valid id.
+func initParameters(dir string) []parameters.Parameter {
+	var id interface{}
+	if regexp.MustCompile(namePattern).MatchString(dir) {
+		id = dir
+	}
+
+	var author interface{}
+	if u, err := user.Current(); err == nil && regexp.MustCompile(namePattern).MatchString(u.Username) {
+		author = u.Username
+	}
+
+	return []parameters.Parameter{
+		{Name: "id", Type: parameters.String, Prompt: "Template id", Pattern: namePattern, Default: id, Required: true},
+		{Name: "author", Type: parameters.String, Prompt: "Author", Pattern: namePattern, Default: author, Required: true},
+		{Name: "display", Type: parameters.String, Prompt: "Display name", Default: ""},
+	}
+}
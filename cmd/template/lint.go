@@ -0,0 +1,85 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chelnak/pdk/internal/stringutils"
+	"github.com/chelnak/pdk/pkg/pct_config_processor"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+const configFile = "pct-config.yml"
+
+func getLintCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint [path]",
+		Short: "Checks a template's pct-config.yml against the schema.",
+		Long: `Checks a template's pct-config.yml against the schema.
+
+The path can be a template directory or a pct-config.yml file. If it is omitted, the current working
+directory is used. Every problem is reported with the line and column it was found at.
+
+Use --schema to print the JSON Schema that is used.`,
+		Args: cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		},
+		RunE: lintRunE,
+	}
+
+	cmd.Flags().Bool("schema", false, "Print the JSON Schema for pct-config.yml and exit.")
+
+	return cmd
+}
+
+func lintRunE(cmd *cobra.Command, args []string) error {
+	if printSchema, _ := cmd.Flags().GetBool("schema"); printSchema {
+		fmt.Println(pct_config_processor.Schema)
+		return nil
+	}
+
+	file, err := resolveConfigFile(args)
+	if err != nil {
+		return err
+	}
+
+	processor := &pct_config_processor.PctConfigProcessor{AFS: &afero.Afero{Fs: afero.NewOsFs()}}
+	problems, err := processor.Lint(file)
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.NotFound, err, "could not read %s", file)
+	}
+
+	if len(problems) == 0 {
+		fmt.Printf("%s is valid\n", file)
+		return nil
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+
+	return pdk_errors.New(pdk_errors.InvalidTemplate, "found %d %s in %s", len(problems), stringutils.Pluralize(len(problems), "problem"), file)
+}
+
+// resolveConfigFile returns the pct-config.yml that args point to.
+func resolveConfigFile(args []string) (string, error) {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", pdk_errors.Wrap(pdk_errors.NotFound, err, "no template at %s", path)
+	}
+
+	if info.IsDir() {
+		path = filepath.Join(path, configFile)
+	}
+
+	return filepath.Clean(path), nil
+}
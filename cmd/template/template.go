@@ -0,0 +1,20 @@
+// Package template contains commands for authoring content templates.
+package template
+
+import "github.com/spf13/cobra"
+
+// GetTemplateCmd returns a cobra.Command that implements functionality for
+// authoring puppet content templates.
+func GetTemplateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "template",
+		Short: "Commands for authoring puppet content templates.",
+		Long:  "Commands for authoring puppet content templates.",
+	}
+
+	cmd.AddCommand(getLintCmd())
+	cmd.AddCommand(getInitCmd())
+	cmd.AddCommand(getTestCmd())
+
+	return cmd
+}
@@ -0,0 +1,138 @@
+package template
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/internal/stringutils"
+	"github.com/chelnak/pdk/internal/utils"
+	"github.com/chelnak/pdk/internal/utils/terminal"
+	"github.com/chelnak/pdk/pkg/fixture"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	updateExpected bool
+	fixtureNames   []string
+	noColor        bool
+)
+
+func getTestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test [path]",
+		Short: "Renders a template with its test fixtures and compares the output with the expected files.",
+		Long: `Renders a template with its test fixtures and compares the output with the expected files.
+
+Each directory in tests is a fixture. It holds a values.yml file with the parameter values to render the
+template with, and the output that they are expected to produce in an expected directory. Every missing,
+unexpected or different file is reported, with a diff for files that are different.
+
+Use --update to replace the expected output with what the template renders now, and review the changes
+before committing them. The path defaults to the current working directory.`,
+		Args: cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		},
+		RunE: testRunE,
+	}
+
+	cmd.Flags().BoolVar(&updateExpected, "update", false, "Replace the expected output of each fixture with the rendered output.")
+	cmd.Flags().StringArrayVar(&fixtureNames, "fixture", nil, "Only run the named fixture. Can be given more than once.")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "Disable color output.")
+
+	return cmd
+}
+
+func testRunE(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	if !terminal.IsTTY() {
+		noColor = true
+	}
+
+	runner := fixture.NewRunner()
+	names, err := runner.List(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(fixtureNames) > 0 {
+		for _, name := range fixtureNames {
+			if !utils.Contains(names, name) {
+				return pdk_errors.New(pdk_errors.Usage, "%s has no fixture named %s", dir, name)
+			}
+		}
+
+		names = fixtureNames
+	}
+
+	if len(names) == 0 {
+		return pdk_errors.New(pdk_errors.NotFound, "%s has no fixtures. Add a directory with a %s file to %s", dir, fixture.ValuesFile, fixture.TestsDir)
+	}
+
+	var failed int
+	for _, name := range names {
+		result, err := runner.Run(dir, name, updateExpected)
+		if err != nil {
+			return err
+		}
+
+		if updateExpected {
+			fmt.Printf("updated %s\n", name)
+			continue
+		}
+
+		if result.Passed() {
+			fmt.Printf("ok   %s\n", name)
+			continue
+		}
+
+		failed++
+		fmt.Printf("FAIL %s\n", name)
+		for _, path := range result.Missing {
+			fmt.Printf("  %s: expected but not rendered\n", path)
+		}
+		for _, path := range result.Unexpected {
+			fmt.Printf("  %s: rendered but not expected\n", path)
+		}
+		for _, c := range result.Different {
+			fmt.Printf("  %s: different\n", c.Path)
+		}
+
+		if err := printDiffs(result.Different); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return pdk_errors.New(pdk_errors.TemplateTest, "%d of %d %s failed", failed, len(names), stringutils.Pluralize(len(names), "fixture"))
+	}
+
+	return nil
+}
+
+func printDiffs(changes []render.Change) error {
+	for _, c := range changes {
+		diff, err := fixture.Diff(c)
+		if err != nil {
+			return err
+		}
+
+		if diff == "" {
+			continue
+		}
+
+		fmt.Println()
+		if err := config.PrintDiff(diff, noColor, os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
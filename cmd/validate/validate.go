@@ -2,22 +2,192 @@
 // with the configured backend.
 package validate
 
-import "github.com/spf13/cobra"
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/chelnak/pdk/cmd/completion"
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/internal/stringutils"
+	"github.com/chelnak/pdk/internal/tools"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/tool"
+	"github.com/spf13/cobra"
+)
 
 // GetValidateCmd returns a cobra.Command that implements functionality
 // for validating a puppet content. It will use installed tools that support
 // validation.
 func GetValidateCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "validate",
+		Use:   "validate [tool...]",
 		Short: "Validates Puppet Content with a given tool.",
-		Long:  "Validates Puppet Content with a given tool.",
-		RunE:  validateRunE,
+		Long: `Validates Puppet Content with a given tool.
+
+Each tool is given as author/id or author/id/version and must have the validate capability. When no tool
+is given, every installed tool with the validate capability is run. The tools run in code_dir, or the
+current working directory if that is not set, one after the other. Validation fails if any of them fails.
+
+Tools that declare an output format have their output turned in to results. With results_view set to
+terminal the results are listed. With results_view set to file they are written to pdk-results.json in
+the directory that was validated. Other tools have their output shown as it is.`,
+		ValidArgsFunction: completion.Tools,
+		RunE:              validateRunE,
 	}
 
 	return cmd
 }
 
 func validateRunE(cmd *cobra.Command, args []string) error {
+	selected, err := selectTools(args)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	var results []tool.Result
+	for _, t := range selected {
+		fmt.Printf("==> %s/%s\n", t.Name(), t.Version)
+
+		toolResults, err := run(cmd, t)
+		if pdk_errors.KindOf(err) == pdk_errors.Tool {
+			failed = append(failed, t.Name())
+		} else if err != nil {
+			return err
+		}
+
+		results = append(results, toolResults...)
+	}
+
+	if config.Config.ResultsView == "file" {
+		if err := writeResults(results); err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		return pdk_errors.New(pdk_errors.Tool, "%d of %d %s failed", len(failed), len(selected), stringutils.Pluralize(len(selected), "tool"))
+	}
+
+	return nil
+}
+
+// selectTools returns the named tools, or every tool that can validate if
+// no names are given.
+func selectTools(names []string) ([]tools.Installed, error) {
+	var selected []tools.Installed
+	for _, name := range names {
+		t, err := tools.Find(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !t.Info.Tool.Supports(tool.Validate) {
+			return nil, pdk_errors.New(pdk_errors.ToolUnsupported, "%s can not validate content", t.Name())
+		}
+
+		selected = append(selected, t)
+	}
+
+	if len(names) > 0 {
+		return selected, nil
+	}
+
+	installed, err := tools.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range installed {
+		if t.Info.Tool.Supports(tool.Validate) {
+			selected = append(selected, t)
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, pdk_errors.New(pdk_errors.NotFound, "no tools that can validate content are installed in %s", config.ToolPath())
+	}
+
+	return selected, nil
+}
+
+// run runs t and returns its results. The output of tools that declare an
+// output format is parsed rather than shown.
+func run(cmd *cobra.Command, t tools.Installed) ([]tool.Result, error) {
+	output := t.Info.Tool.Output
+
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+	if output.Parses() {
+		stdout = nil
+		if output.Stream == "stderr" {
+			stderr = nil
+		}
+	}
+
+	result, runErr := tools.Run(cmd.Context(), t, tool.Validate, nil, stdout, stderr)
+	if pdk_errors.KindOf(runErr) != pdk_errors.Tool && runErr != nil {
+		return nil, runErr
+	}
+
+	results, err := output.Parse(result.Stdout, result.Stderr)
+	if err != nil {
+		return nil, pdk_errors.Wrap(pdk_errors.Tool, err, "could not read the results of %s", t.Name())
+	}
+
+	for i := range results {
+		results[i].Tool = t.Name()
+	}
+
+	if output.Parses() && config.Config.ResultsView != "file" {
+		if err := printResults(results); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, runErr
+}
+
+func printResults(results []tool.Result) error {
+	if len(results) == 0 {
+		fmt.Println("No problems found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LOCATION\tSEVERITY\tRULE\tMESSAGE")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Location(), r.Severity, r.Rule, r.Message)
+	}
+
+	return w.Flush()
+}
+
+// writeResults writes results to the results file in the directory that was
+// validated.
+func writeResults(results []tool.Result) error {
+	dir, err := tools.Dir()
+	if err != nil {
+		return err
+	}
+
+	if results == nil {
+		results = []tool.Result{}
+	}
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, tools.ResultsFile)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not write %s", path)
+	}
+
+	fmt.Printf("Wrote %d %s to %s\n", len(results), stringutils.Pluralize(len(results), "result"), path)
 	return nil
 }
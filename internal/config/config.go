@@ -7,12 +7,20 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/alecthomas/chroma"
 	"github.com/alecthomas/chroma/formatters"
 	"github.com/alecthomas/chroma/lexers"
 	"github.com/alecthomas/chroma/styles"
 
+	"github.com/chelnak/pdk/pkg/credentials"
+	"github.com/chelnak/pdk/pkg/network"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v2"
 )
@@ -21,88 +29,334 @@ import (
 // initialized by the InitConfig method.
 var Config config
 
+const envPrefix = "PDK"
+
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
 type config struct {
-	AlwaysBuild   bool   `json:"always_build" yaml:"always_build" mapstructure:"always_build"`
-	Backend       string `json:"backend" yaml:"backend" mapstructure:"backend"`
-	CacheDir      string `json:"cache_dir" yaml:"cache_dir" mapstructure:"cache_dir"`
-	CodeDir       string `json:"code_dir" yaml:"code_dir" mapstructure:"code_dir"`
-	PuppetVersion string `json:"puppet_version" yaml:"puppet_version" mapstructure:"puppet_version"`
-	ResultsView   string `json:"results_view" yaml:"results_view" mapstructure:"results_view"`
-	ToolArgs      string `json:"tool_args" yaml:"tool_args" mapstructure:"tool_args"`
-	ToolPath      string `json:"tool_path" yaml:"tool_path" mapstructure:"tool_path"`
-	ToolTimeout   int    `json:"tool_timeout" yaml:"tool_timeout" mapstructure:"tool_timeout"`
+	AlwaysBuild     bool   `json:"always_build" yaml:"always_build" mapstructure:"always_build"`
+	Backend         string `json:"backend" yaml:"backend" mapstructure:"backend"`
+	BuildTimeout    int    `json:"build_timeout" yaml:"build_timeout" mapstructure:"build_timeout"`
+	CAFile          string `json:"ca_file" yaml:"ca_file" mapstructure:"ca_file"`
+	CacheDir        string `json:"cache_dir" yaml:"cache_dir" mapstructure:"cache_dir"`
+	ClientCert      string `json:"client_cert" yaml:"client_cert" mapstructure:"client_cert"`
+	ClientKey       string `json:"client_key" yaml:"client_key" mapstructure:"client_key"`
+	CodeDir         string `json:"code_dir" yaml:"code_dir" mapstructure:"code_dir"`
+	DownloadMaxSize int    `json:"download_max_size" yaml:"download_max_size" mapstructure:"download_max_size"` // in megabytes
+	DownloadRetries int    `json:"download_retries" yaml:"download_retries" mapstructure:"download_retries"`
+	HookTimeout     int    `json:"hook_timeout" yaml:"hook_timeout" mapstructure:"hook_timeout"`
+	InstallTimeout  int    `json:"install_timeout" yaml:"install_timeout" mapstructure:"install_timeout"`
+	Offline         bool   `json:"offline" yaml:"offline" mapstructure:"offline"`
+	PuppetVersion   string `json:"puppet_version" yaml:"puppet_version" mapstructure:"puppet_version"`
+	ResultsView     string `json:"results_view" yaml:"results_view" mapstructure:"results_view"`
+	TemplatePath    string `json:"template_path" yaml:"template_path" mapstructure:"template_path"`
+	ToolArgs        string `json:"tool_args" yaml:"tool_args" mapstructure:"tool_args"`
+	ToolPath        string `json:"tool_path" yaml:"tool_path" mapstructure:"tool_path"`
+	ToolTimeout     int    `json:"tool_timeout" yaml:"tool_timeout" mapstructure:"tool_timeout"`
+
+	// Credentials holds the credentials used when downloading packages from
+	// private hosts.
+	Credentials []credentials.Credential `json:"credentials,omitempty" yaml:"credentials,omitempty" mapstructure:"credentials"`
+
+	// Profile is the name of the profile that was applied, if any.
+	Profile string `json:"profile,omitempty" yaml:"profile,omitempty" mapstructure:"-"`
+}
+
+// InitOptions controls how InitConfig loads the configuration.
+type InitOptions struct {
+	// File is the path to a config file. If empty the default config file
+	// located at $HOME/.config/puppetlabs/pdk/.pdk.yaml is used and created
+	// if it does not exist.
+	File string
+
+	// Profile is the name of a profile to apply. If empty the profile selected
+	// with PDK_PROFILE or the profile key is used.
+	Profile string
+
+	// SkipMigration disables the automatic migration of older config files.
+	SkipMigration bool
+
+	// Offline enables offline mode regardless of the offline key.
+	Offline bool
 }
 
-func InitConfig(cfgFile string) error {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
+// SkipMigrationAnnotation can be added to the annotations of a command to
+// prevent the config file from being migrated before the command runs.
+const SkipMigrationAnnotation = "pdk/skip-config-migration"
+
+// InitConfig initializes the package level Config variable.
+//
+// Every key can be overridden with an environment variable. See EnvVarName for
+// how keys map to environment variables.
+//
+// If a profile has been selected, the values of that profile are applied on
+// top of the configuration file. Environment variables still take precedence.
+//
+// Config files written by an older version of the pdk are migrated to
+// CurrentVersion unless opts.SkipMigration is true. The default config file
+// is saved after it is migrated if it can be written. A file given in
+// opts.File is only migrated in memory and never rewritten.
+func InitConfig(opts InitOptions) error {
+	setDefaults()
+
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(envKeyReplacer)
+	viper.AutomaticEnv()
+
+	if opts.File != "" {
+		viper.SetConfigFile(opts.File)
 
 		if err := viper.ReadInConfig(); err != nil {
-			return fmt.Errorf("error reading config file: %v", err)
+			return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "error reading config file")
 		}
 	} else {
-		home, _ := os.UserHomeDir()
-
 		viper.SetConfigName(".pdk")
 		viper.SetConfigType("yaml")
 
-		cfgPath := filepath.Join(home, ".config", "puppetlabs", "pdk")
+		cfgPath := Dir()
 		viper.AddConfigPath(cfgPath)
 
 		if _, err := os.Stat(cfgPath); os.IsNotExist(err) {
 			if err := os.MkdirAll(cfgPath, 0750); err != nil {
-				return fmt.Errorf("failed to create config directory: %s", err)
+				return pdk_errors.Wrap(pdk_errors.FileSystem, err, "failed to create config directory")
 			}
 		}
 
-		setDefaults()
-
 		if err := viper.ReadInConfig(); err != nil {
 			err := viper.SafeWriteConfig()
 			if err != nil {
-				return fmt.Errorf("failed to write config: %s", err)
+				return pdk_errors.Wrap(pdk_errors.FileSystem, err, "failed to write config")
+			}
+
+			if err := viper.ReadInConfig(); err != nil {
+				return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "error reading config file")
 			}
+
+			log.Debug().Str("file", viper.ConfigFileUsed()).Msg("created default config file")
 		}
+	}
 
-		viper.AutomaticEnv()
-		viper.SetEnvPrefix("PDK")
+	log.Debug().Str("file", viper.ConfigFileUsed()).Msg("loaded config file")
 
-		if err := viper.Unmarshal(&Config); err != nil {
-			return fmt.Errorf("failed to unmarshal config: %s", err)
+	for name := range ActiveEnv() {
+		log.Debug().Str("variable", name).Msg("config overridden by environment")
+	}
+
+	if file := viper.ConfigFileUsed(); file != "" && !opts.SkipMigration {
+		if err := migrateOnLoad(file, opts.File == ""); err != nil {
+			return err
 		}
 	}
 
+	if err := viper.Unmarshal(&Config); err != nil {
+		return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "failed to unmarshal config")
+	}
+
+	profile := opts.Profile
+	if profile == "" {
+		profile = viper.GetString(profileKey)
+	}
+
+	if err := applyProfile(profile); err != nil {
+		return err
+	}
+
+	if opts.Offline {
+		Config.Offline = true
+	}
+
+	network.SetOffline(Config.Offline)
+
+	log.Debug().Interface("config", Config).Msg("resolved config")
 	return nil
 }
 
+// Dir returns the default pdk config directory,
+// $HOME/.config/puppetlabs/pdk.
+func Dir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "puppetlabs", "pdk")
+}
+
+// TemplatePath returns the directory that templates are installed to. It
+// defaults to the templates directory inside the pdk config directory.
+func TemplatePath() string {
+	if Config.TemplatePath != "" {
+		return Config.TemplatePath
+	}
+
+	return filepath.Join(Dir(), "templates")
+}
+
+// ToolPath returns the directory that tools are installed to. It defaults
+// to the tools directory inside the pdk config directory.
+func ToolPath() string {
+	if Config.ToolPath != "" {
+		return Config.ToolPath
+	}
+
+	return filepath.Join(Dir(), "tools")
+}
+
+// CredentialStore returns the configured credentials.
+func CredentialStore() *credentials.Store {
+	return &credentials.Store{Credentials: Config.Credentials}
+}
+
+// NetworkOptions returns the configured TLS settings.
+func NetworkOptions() network.Options {
+	return network.Options{
+		CAFile:     Config.CAFile,
+		ClientCert: Config.ClientCert,
+		ClientKey:  Config.ClientKey,
+	}
+}
+
+// Timeout returns the given number of seconds as a time.Duration. A value of
+// zero or less means that there is no timeout and zero is returned.
+func Timeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// Keys returns the names of all supported configuration keys that hold a
+// single value, in alphabetical order. Keys that hold lists or maps, such as
+// credentials, can only be changed by editing the config file.
+func Keys() []string {
+	var keys []string
+	t := reflect.TypeOf(config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if key := field.Tag.Get("mapstructure"); key != "-" && field.Type.Kind() != reflect.Map && field.Type.Kind() != reflect.Slice {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// IsKey returns true if key is a supported configuration key.
+func IsKey(key string) bool {
+	for _, k := range Keys() {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EnvVarName returns the name of the environment variable that overrides the
+// given configuration key. Keys are upper cased and prefixed with PDK_, for
+// example:
+//
+//	always_build      -> PDK_ALWAYS_BUILD
+//	backend           -> PDK_BACKEND
+//	build_timeout     -> PDK_BUILD_TIMEOUT
+//	ca_file           -> PDK_CA_FILE
+//	cache_dir         -> PDK_CACHE_DIR
+//	client_cert       -> PDK_CLIENT_CERT
+//	client_key        -> PDK_CLIENT_KEY
+//	code_dir          -> PDK_CODE_DIR
+//	download_max_size -> PDK_DOWNLOAD_MAX_SIZE
+//	download_retries  -> PDK_DOWNLOAD_RETRIES
+//	hook_timeout      -> PDK_HOOK_TIMEOUT
+//	install_timeout   -> PDK_INSTALL_TIMEOUT
+//	offline           -> PDK_OFFLINE
+//	puppet_version    -> PDK_PUPPET_VERSION
+//	results_view      -> PDK_RESULTS_VIEW
+//	template_path     -> PDK_TEMPLATE_PATH
+//	tool_args         -> PDK_TOOL_ARGS
+//	tool_path         -> PDK_TOOL_PATH
+//	tool_timeout      -> PDK_TOOL_TIMEOUT
+func EnvVarName(key string) string {
+	key = envKeyReplacer.Replace(key)
+	return fmt.Sprintf("%s_%s", envPrefix, strings.ToUpper(key))
+}
+
+// ActiveEnv returns the environment variables that are currently overriding
+// configuration keys, keyed by environment variable name.
+func ActiveEnv() map[string]string {
+	env := make(map[string]string)
+	for _, key := range Keys() {
+		name := EnvVarName(key)
+		if value, ok := os.LookupEnv(name); ok {
+			env[name] = value
+		}
+	}
+
+	return env
+}
+
 func setDefaults() {
 	// PRM config defaults
 	viper.SetDefault("always_build", false)
 	viper.SetDefault("backend", "docker")
+	viper.SetDefault("build_timeout", 300)
+	viper.SetDefault("ca_file", "")
 	viper.SetDefault("cache_dir", "")
+	viper.SetDefault("client_cert", "")
+	viper.SetDefault("client_key", "")
 	viper.SetDefault("code_dir", "")
+	viper.SetDefault("download_max_size", 1024)
+	viper.SetDefault("download_retries", 3)
+	viper.SetDefault("hook_timeout", 300)
+	viper.SetDefault("install_timeout", 600)
+	viper.SetDefault("offline", false)
 	viper.SetDefault("puppet_version", "7.14.0")
 	viper.SetDefault("results_view", "terminal")
 	viper.SetDefault("tool_args", "")
-	viper.SetDefault("tool_path", "") // this should be configured config directory /tools
+	viper.SetDefault("template_path", "")
+	viper.SetDefault("tool_path", "")
 	viper.SetDefault("tool_timeout", 1800)
+
+	viper.SetDefault(versionKey, CurrentVersion)
 }
 
 // Set sets the value of the given key to the given value.
 // This method needs far more validation that it currently has.
 // it also needs to be able to handle complex types and should probably
 // be validated against a schema/struct.
+//
+// Only the key is written to the config file. Defaults and environment
+// overrides are not, so the file is read again on its own before the key is
+// set.
 func Set(key string, value interface{}) error {
-	viper.Set(key, value)
-
-	err := viper.WriteConfig()
+	v, err := readFile(viper.ConfigFileUsed())
 	if err != nil {
 		return err
 	}
 
+	v.Set(key, value)
+
+	if err := v.WriteConfig(); err != nil {
+		return pdk_errors.Wrap(pdk_errors.FileSystem, err, "failed to write config")
+	}
+
+	viper.Set(key, value)
 	return nil
 }
 
+// readFile returns a viper instance that holds only the contents of the
+// given config file. Files without an extension are read as YAML.
+func readFile(configFile string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	if filepath.Ext(configFile) == "" {
+		v.SetConfigType("yaml")
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "error reading config file")
+	}
+
+	return v, nil
+}
+
 type writeOptions struct {
 	data      string
 	lexerName string
@@ -140,13 +394,7 @@ func prettyWrite(opts writeOptions) error {
 
 // PrintJSON prints the current configuration to the terminal in JSON format.
 func PrintJSON(noColor bool, writer io.Writer) error {
-	var ifac interface{}
-	err := viper.Unmarshal(&ifac)
-	if err != nil {
-		return err
-	}
-
-	b, err := json.MarshalIndent(ifac, "", "  ")
+	b, err := json.MarshalIndent(Config, "", "  ")
 	b = append(b, '\n')
 	if err != nil {
 		return err
@@ -164,13 +412,7 @@ func PrintJSON(noColor bool, writer io.Writer) error {
 
 // PrintYAML prints the current configuration to the terminal in YAML format.
 func PrintYAML(noColor bool, writer io.Writer) error {
-	var ifac interface{}
-	err := viper.Unmarshal(&ifac)
-	if err != nil {
-		return err
-	}
-
-	b, err := yaml.Marshal(ifac)
+	b, err := yaml.Marshal(Config)
 	y := []byte("---\n")
 	y = append(y, b...)
 	if err != nil {
@@ -186,3 +428,41 @@ func PrintYAML(noColor bool, writer io.Writer) error {
 
 	return prettyWrite(opts)
 }
+
+// PrintEnv prints the environment variables that are currently overriding
+// configuration keys to the terminal.
+func PrintEnv(noColor bool, writer io.Writer) error {
+	env := ActiveEnv()
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%q\n", name, env[name])
+	}
+
+	opts := writeOptions{
+		data:      b.String(),
+		lexerName: "bash",
+		noColor:   noColor,
+		writer:    writer,
+	}
+
+	return prettyWrite(opts)
+}
+
+// PrintDiff prints a unified diff to the terminal.
+func PrintDiff(diff string, noColor bool, writer io.Writer) error {
+	opts := writeOptions{
+		data:      diff,
+		lexerName: "diff",
+		noColor:   noColor,
+		writer:    writer,
+	}
+
+	return prettyWrite(opts)
+}
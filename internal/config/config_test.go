@@ -0,0 +1,290 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/chelnak/pdk/pkg/network"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/spf13/viper"
+)
+
+// setup points HOME at a temp dir that holds the given default config file
+// and resets the global state that InitConfig changes.
+func setup(t *testing.T, content string) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	viper.Reset()
+	Config = config{}
+	t.Cleanup(func() {
+		viper.Reset()
+		Config = config{}
+		network.SetOffline(false)
+	})
+
+	path := filepath.Join(Dir(), ".pdk.yaml")
+	if content != "" {
+		if err := os.MkdirAll(Dir(), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path
+}
+
+func TestEnvVarName(t *testing.T) {
+	tests := map[string]string{
+		"backend":           "PDK_BACKEND",
+		"tool_timeout":      "PDK_TOOL_TIMEOUT",
+		"download_max_size": "PDK_DOWNLOAD_MAX_SIZE",
+		"dotted.key":        "PDK_DOTTED_KEY",
+		"dashed-key":        "PDK_DASHED_KEY",
+	}
+
+	for key, want := range tests {
+		if got := EnvVarName(key); got != want {
+			t.Errorf("EnvVarName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestActiveEnv(t *testing.T) {
+	t.Setenv("PDK_TOOL_TIMEOUT", "5")
+	t.Setenv("PDK_BACKEND", "local")
+	t.Setenv("PDK_NOT_A_KEY", "x")
+
+	want := map[string]string{"PDK_TOOL_TIMEOUT": "5", "PDK_BACKEND": "local"}
+	if got := ActiveEnv(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInitConfigCreatesDefaultFile(t *testing.T) {
+	path := setup(t, "")
+
+	if err := InitConfig(InitOptions{}); err != nil {
+		t.Fatalf("InitConfig() returned an error: %v", err)
+	}
+
+	if Config.Backend != "docker" || Config.ToolTimeout != 1800 {
+		t.Errorf("got backend %q and tool_timeout %d, want the defaults", Config.Backend, Config.ToolTimeout)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("the default config file was not created: %v", err)
+	}
+}
+
+func TestInitConfigPrecedence(t *testing.T) {
+	setup(t, `config_version: 1
+backend: docker
+tool_timeout: 10
+hook_timeout: 20
+puppet_version: 7.14.0
+profile: ci
+profiles:
+  ci:
+    backend: local
+    tool_timeout: 50
+    puppet_version: 8.0.0
+`)
+	t.Setenv("PDK_TOOL_TIMEOUT", "99")
+	t.Setenv("PDK_HOOK_TIMEOUT", "30")
+
+	if err := InitConfig(InitOptions{}); err != nil {
+		t.Fatalf("InitConfig() returned an error: %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		got  interface{}
+		want interface{}
+	}{
+		{key: "backend from the profile", got: Config.Backend, want: "local"},
+		{key: "puppet_version from the profile", got: Config.PuppetVersion, want: "8.0.0"},
+		{key: "tool_timeout from the env over the profile", got: Config.ToolTimeout, want: 99},
+		{key: "hook_timeout from the env over the file", got: Config.HookTimeout, want: 30},
+		{key: "install_timeout from the defaults", got: Config.InstallTimeout, want: 600},
+		{key: "profile", got: Config.Profile, want: "ci"},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.key, tt.got, tt.want)
+		}
+	}
+}
+
+func TestInitConfigProfileOption(t *testing.T) {
+	setup(t, "config_version: 1\nprofiles:\n  local:\n    offline: true\n")
+
+	if err := InitConfig(InitOptions{Profile: "local"}); err != nil {
+		t.Fatalf("InitConfig() returned an error: %v", err)
+	}
+
+	if !Config.Offline || !network.Offline() {
+		t.Error("the offline value of the profile was not applied")
+	}
+}
+
+func TestInitConfigMissingProfile(t *testing.T) {
+	setup(t, "config_version: 1\n")
+
+	err := InitConfig(InitOptions{Profile: "missing"})
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.ProfileNotFound {
+		t.Errorf("got kind %s, want %s: %v", kind.Code, pdk_errors.ProfileNotFound.Code, err)
+	}
+}
+
+func TestSetWritesOnlyTheFile(t *testing.T) {
+	path := setup(t, "config_version: 1\nbackend: docker\n")
+	t.Setenv("PDK_TOOL_TIMEOUT", "5")
+
+	if err := InitConfig(InitOptions{}); err != nil {
+		t.Fatalf("InitConfig() returned an error: %v", err)
+	}
+
+	if err := Set("backend", "local"); err != nil {
+		t.Fatalf("Set() returned an error: %v", err)
+	}
+
+	v, err := readFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"backend", "config_version"}
+	got := v.AllKeys()
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %q in the file, want %q", got, want)
+	}
+
+	if v.GetString("backend") != "local" {
+		t.Errorf("got backend %q, want %q", v.GetString("backend"), "local")
+	}
+}
+
+func TestCreateProfile(t *testing.T) {
+	path := setup(t, "config_version: 1\n")
+
+	if err := InitConfig(InitOptions{}); err != nil {
+		t.Fatalf("InitConfig() returned an error: %v", err)
+	}
+
+	err := CreateProfile("ci", map[string]string{"tool_timeout": "50", "offline": "true", "backend": "local"})
+	if err != nil {
+		t.Fatalf("CreateProfile() returned an error: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"tool_timeout: 50\n", "offline: true\n", "backend: local\n"} {
+		if !strings.Contains(string(content), line) {
+			t.Errorf("the config file does not contain %q:\n%s", line, content)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		kind   pdk_errors.Kind
+	}{
+		{name: "ci", values: nil, kind: pdk_errors.Usage},
+		{name: "bad", values: map[string]string{"unknown": "1"}, kind: pdk_errors.Usage},
+		{name: "bad", values: map[string]string{"tool_timeout": "soon"}, kind: pdk_errors.InvalidConfig},
+		{name: "bad", values: map[string]string{"offline": "maybe"}, kind: pdk_errors.InvalidConfig},
+		{name: "bad", values: map[string]string{"backend": "podman"}, kind: pdk_errors.InvalidConfig},
+		{name: "a.b", values: nil, kind: pdk_errors.Usage},
+	}
+
+	for _, tt := range tests {
+		err := CreateProfile(tt.name, tt.values)
+		if kind := pdk_errors.KindOf(err); kind != tt.kind {
+			t.Errorf("CreateProfile(%q, %v): got kind %s, want %s: %v", tt.name, tt.values, kind.Code, tt.kind.Code, err)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		want  interface{}
+	}{
+		{key: "tool_timeout", value: "50", want: 50},
+		{key: "offline", value: "true", want: true},
+		{key: "backend", value: "local", want: "local"},
+		{key: "puppet_version", value: "8", want: "8"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseValue(tt.key, tt.value)
+		if err != nil {
+			t.Errorf("ParseValue(%q, %q) returned an error: %v", tt.key, tt.value, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseValue(%q, %q) = %#v, want %#v", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		kind   pdk_errors.Kind
+		want   string
+	}{
+		{
+			name:   "valid",
+			config: "config_version: 1\nbackend: local\nclient_cert: cert.pem\nclient_key: key.pem\nprofile: ci\nprofiles:\n  ci:\n    offline: true\n",
+		},
+		{name: "invalid yaml", config: "backend: [", kind: pdk_errors.InvalidConfig, want: "invalid yaml"},
+		{name: "unknown key", config: "colour: red\n", kind: pdk_errors.InvalidConfig, want: "colour"},
+		{name: "wrong type", config: "tool_timeout: soon\n", kind: pdk_errors.InvalidConfig, want: "tool_timeout"},
+		{name: "backend", config: "backend: podman\n", kind: pdk_errors.InvalidConfig, want: `invalid value "podman" for backend`},
+		{name: "results_view", config: "results_view: web\n", kind: pdk_errors.InvalidConfig, want: "results_view"},
+		{name: "negative build_timeout", config: "build_timeout: -1\n", kind: pdk_errors.InvalidConfig, want: "build_timeout must not be negative"},
+		{name: "negative install_timeout", config: "install_timeout: -1\n", kind: pdk_errors.InvalidConfig, want: "install_timeout must not be negative"},
+		{name: "negative tool_timeout", config: "tool_timeout: -1\n", kind: pdk_errors.InvalidConfig, want: "tool_timeout must not be negative"},
+		{name: "cert without key", config: "client_cert: cert.pem\n", kind: pdk_errors.InvalidConfig, want: "client_cert and client_key must be set together"},
+		{name: "key without cert", config: "client_key: key.pem\n", kind: pdk_errors.InvalidConfig, want: "client_cert and client_key must be set together"},
+		{name: "invalid profile", config: "profiles:\n  ci:\n    backend: podman\n", kind: pdk_errors.InvalidConfig, want: `profile "ci"`},
+		{name: "missing profile", config: "profile: ci\n", kind: pdk_errors.ProfileNotFound, want: `profile "ci" does not exist`},
+		{name: "newer version", config: "config_version: 99\n", kind: pdk_errors.InvalidConfig, want: "config_version 99 is not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]byte(tt.config))
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() returned an error: %v", err)
+				}
+				return
+			}
+
+			if kind := pdk_errors.KindOf(err); kind != tt.kind {
+				t.Errorf("got kind %s, want %s: %v", kind.Code, tt.kind.Code, err)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config file version understood by this version of
+// the pdk. It is written to the config_version key of the config file.
+const CurrentVersion = 1
+
+const versionKey = "config_version"
+
+// migration upgrades a config file from version-1 to version.
+type migration struct {
+	version     int
+	description string
+	migrate     func(settings map[string]interface{}) error
+}
+
+// migrations holds every config migration in the order that they must be
+// applied. When the layout of the config file changes, add a new entry here
+// and bump CurrentVersion.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "Add config_version marker",
+		migrate: func(settings map[string]interface{}) error {
+			return nil
+		},
+	},
+}
+
+// MigrationResult describes the outcome of a config file migration.
+type MigrationResult struct {
+	File        string
+	FromVersion int
+	ToVersion   int
+	Applied     []string
+	BackupFile  string
+	Diff        string
+}
+
+// Migrate upgrades the given config file to CurrentVersion, one version at a
+// time. Unless dryRun is true, a backup of the original file is written next
+// to it before the migrated config is saved in the format of the original
+// file. When only the version changes, the rest of the file is kept as it
+// is. The returned result always contains a unified diff of the changes.
+func Migrate(configFile string, dryRun bool) (MigrationResult, error) {
+	result, original, migrated, err := migrate(configFile)
+	if err != nil || result.ToVersion == result.FromVersion || dryRun {
+		return result, err
+	}
+
+	return save(result, original, migrated)
+}
+
+// migrate returns the result of migrating the given config file together
+// with its original and migrated contents. The file is not changed.
+func migrate(configFile string) (result MigrationResult, original, migrated []byte, err error) {
+	result.File = configFile
+
+	original, err = os.ReadFile(configFile) // #nosec G304 -- path is provided by the user
+	if err != nil {
+		return result, nil, nil, pdk_errors.Wrap(pdk_errors.FileSystem, err, "failed to read config file")
+	}
+
+	v, err := readFile(configFile)
+	if err != nil {
+		return result, nil, nil, err
+	}
+
+	// AllSettings returns a new map on every call, so before is left alone
+	// by the migrations.
+	before, settings := v.AllSettings(), v.AllSettings()
+	result.FromVersion = v.GetInt(versionKey)
+	result.ToVersion = result.FromVersion
+
+	if result.FromVersion > CurrentVersion {
+		return result, nil, nil, pdk_errors.New(pdk_errors.InvalidConfig, "config file %s has version %d but this version of the pdk only supports version %d or lower", configFile, result.FromVersion, CurrentVersion)
+	}
+
+	for _, m := range migrations {
+		if m.version <= result.FromVersion {
+			continue
+		}
+
+		if err := m.migrate(settings); err != nil {
+			return result, nil, nil, pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "failed to migrate config to version %d", m.version)
+		}
+
+		log.Debug().Int("version", m.version).Str("description", m.description).Msg("applied config migration")
+
+		settings[versionKey] = m.version
+		result.ToVersion = m.version
+		result.Applied = append(result.Applied, fmt.Sprintf("v%d: %s", m.version, m.description))
+	}
+
+	if result.ToVersion == result.FromVersion {
+		return result, original, original, nil
+	}
+
+	migrated, err = rewrite(original, before, settings, fileType(configFile))
+	if err != nil {
+		return result, nil, nil, pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "failed to marshal migrated config")
+	}
+
+	result.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(original)),
+		B:        difflib.SplitLines(string(migrated)),
+		FromFile: fmt.Sprintf("%s (v%d)", configFile, result.FromVersion),
+		ToFile:   fmt.Sprintf("%s (v%d)", configFile, result.ToVersion),
+		Context:  3,
+	})
+	if err != nil {
+		return result, nil, nil, err
+	}
+
+	return result, original, migrated, nil
+}
+
+// save writes a backup of the original config file next to it and replaces
+// it with the migrated config. The backup is removed again if the config
+// file can not be written.
+func save(result MigrationResult, original, migrated []byte) (MigrationResult, error) {
+	backup := fmt.Sprintf("%s.v%d.%s.bak", result.File, result.FromVersion, time.Now().Format("20060102150405"))
+	if err := os.WriteFile(backup, original, 0600); err != nil {
+		return result, pdk_errors.Wrap(pdk_errors.FileSystem, err, "failed to back up config file")
+	}
+
+	if err := os.WriteFile(result.File, migrated, 0600); err != nil {
+		_ = os.Remove(backup)
+		return result, pdk_errors.Wrap(pdk_errors.FileSystem, err, "failed to write migrated config")
+	}
+
+	result.BackupFile = backup
+	return result, nil
+}
+
+// fileType returns the format of the given config file from its extension.
+// Files without an extension are YAML.
+func fileType(configFile string) string {
+	if ext := filepath.Ext(configFile); ext != "" {
+		return strings.ToLower(ext[1:])
+	}
+
+	return "yaml"
+}
+
+// rewrite returns the migrated config file. When the migrations only changed
+// the version, the version is edited in the original YAML so that comments,
+// key order and formatting are kept. Otherwise the file is encoded again
+// from settings.
+func rewrite(original []byte, before, settings map[string]interface{}, configType string) ([]byte, error) {
+	version := settings[versionKey]
+	delete(before, versionKey)
+	delete(settings, versionKey)
+	changed := !reflect.DeepEqual(before, settings)
+	settings[versionKey] = version
+
+	if !changed && (configType == "yaml" || configType == "yml") {
+		if migrated, ok := setVersion(original, fmt.Sprint(version)); ok {
+			return migrated, nil
+		}
+	}
+
+	return encode(settings, configType)
+}
+
+// setVersion sets config_version in the YAML document in data by editing
+// the text. The value is replaced where it is, or a line is appended if the
+// key is missing. It returns false if the document is not a block mapping
+// that can be edited this way.
+func setVersion(data []byte, version string) ([]byte, bool) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, false
+	}
+
+	// A file with nothing but comments has no document.
+	if len(doc.Content) > 0 {
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode || root.Style&yaml.FlowStyle != 0 || root.Column != 1 {
+			return nil, false
+		}
+
+		if migrated, found, ok := replaceValue(data, root, version); found {
+			return migrated, ok
+		}
+	}
+
+	migrated := append([]byte{}, data...)
+	if len(migrated) > 0 && migrated[len(migrated)-1] != '\n' {
+		migrated = append(migrated, '\n')
+	}
+
+	return append(migrated, versionKey+": "+version+"\n"...), true
+}
+
+// replaceValue replaces the value of config_version in the mapping root.
+// found is false if the mapping has no config_version key.
+func replaceValue(data []byte, root *yaml.Node, version string) (migrated []byte, found, ok bool) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Value != versionKey {
+			continue
+		}
+
+		if value.Kind != yaml.ScalarNode {
+			return nil, true, false
+		}
+
+		start := offset(data, value.Line, value.Column)
+		if start < 0 || !bytes.HasPrefix(data[start:], []byte(value.Value)) {
+			return nil, true, false
+		}
+
+		migrated = append([]byte{}, data[:start]...)
+		migrated = append(migrated, version...)
+		return append(migrated, data[start+len(value.Value):]...), true, true
+	}
+
+	return nil, false, false
+}
+
+// offset returns the byte offset of the 1-based line and column in data, or
+// -1 if it is out of range.
+func offset(data []byte, line, column int) int {
+	pos := 0
+	for l := 1; l < line; l++ {
+		i := bytes.IndexByte(data[pos:], '\n')
+		if i < 0 {
+			return -1
+		}
+		pos += i + 1
+	}
+
+	if pos+column-1 > len(data) {
+		return -1
+	}
+
+	return pos + column - 1
+}
+
+// encode serializes settings in the given format with the encoders that
+// viper uses to write config files.
+func encode(settings map[string]interface{}, configType string) ([]byte, error) {
+	fs := afero.NewMemMapFs()
+
+	v := viper.New()
+	v.SetFs(fs)
+	v.SetConfigType(configType)
+	for key, value := range settings {
+		v.Set(key, value)
+	}
+
+	name := "/config." + configType
+	if err := v.WriteConfigAs(name); err != nil {
+		return nil, err
+	}
+
+	return afero.ReadFile(fs, name)
+}
+
+// migrateOnLoad migrates the config file that viper has loaded. The migrated
+// config is saved when persist is true and the file can be written.
+// Otherwise it is only applied to the loaded config, and the file is left as
+// it is until 'pdk config migrate' is run.
+func migrateOnLoad(configFile string, persist bool) error {
+	result, original, migrated, err := migrate(configFile)
+	if err != nil {
+		return err
+	}
+
+	if result.ToVersion == result.FromVersion {
+		return nil
+	}
+
+	if persist {
+		result, err = save(result, original, migrated)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "Migrated config file %s from v%d to v%d. A backup was saved to %s\n", configFile, result.FromVersion, result.ToVersion, result.BackupFile)
+
+			if err := viper.ReadInConfig(); err != nil {
+				return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "error reading config file")
+			}
+
+			return nil
+		}
+
+		log.Debug().Err(err).Str("file", configFile).Msg("could not save migrated config")
+	}
+
+	log.Debug().Str("file", configFile).Int("from", result.FromVersion).Int("to", result.ToVersion).Msg("migrated config in memory")
+
+	if err := viper.ReadConfig(bytes.NewReader(migrated)); err != nil {
+		return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "error reading migrated config")
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func readConfig(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+const unversioned = `# Settings for the build agents.
+tool_timeout: 60
+backend: local # no docker on the agents
+
+profiles:
+  ci:
+    offline: true
+`
+
+func TestMigrateKeepsTheFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		original string
+		want     string
+	}{
+		{
+			name:     "append version",
+			file:     ".pdk.yaml",
+			original: unversioned,
+			want:     unversioned + "config_version: 1\n",
+		},
+		{
+			name:     "no trailing newline",
+			file:     ".pdk.yaml",
+			original: "backend: local",
+			want:     "backend: local\nconfig_version: 1\n",
+		},
+		{
+			name:     "replace version",
+			file:     ".pdk.yml",
+			original: "# pinned\nconfig_version: 0 # old\nbackend: local\n",
+			want:     "# pinned\nconfig_version: 1 # old\nbackend: local\n",
+		},
+		{
+			name:     "only comments",
+			file:     ".pdk.yaml",
+			original: "# nothing here yet\n",
+			want:     "# nothing here yet\nconfig_version: 1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.file, tt.original)
+
+			result, err := Migrate(path, false)
+			if err != nil {
+				t.Fatalf("Migrate() returned an error: %v", err)
+			}
+
+			if result.FromVersion != 0 || result.ToVersion != CurrentVersion {
+				t.Errorf("migrated from v%d to v%d, want v0 to v%d", result.FromVersion, result.ToVersion, CurrentVersion)
+			}
+
+			if got := readConfig(t, path); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateJSON(t *testing.T) {
+	path := writeConfig(t, "pdk.json", `{"backend": "local"}`)
+
+	if _, err := Migrate(path, false); err != nil {
+		t.Fatalf("Migrate() returned an error: %v", err)
+	}
+
+	got := readConfig(t, path)
+	if !strings.HasPrefix(strings.TrimSpace(got), "{") || !strings.Contains(got, `"config_version": 1`) || !strings.Contains(got, `"backend": "local"`) {
+		t.Errorf("got %s, want a JSON file with config_version 1", got)
+	}
+}
+
+func TestMigrateBackup(t *testing.T) {
+	path := writeConfig(t, ".pdk.yaml", unversioned)
+
+	result, err := Migrate(path, false)
+	if err != nil {
+		t.Fatalf("Migrate() returned an error: %v", err)
+	}
+
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(path) + `\.v0\.\d{14}\.bak$`)
+	if !pattern.MatchString(result.BackupFile) {
+		t.Errorf("got backup file %s, want %s", result.BackupFile, pattern)
+	}
+
+	if got := readConfig(t, result.BackupFile); got != unversioned {
+		t.Errorf("got backup\n%s\nwant\n%s", got, unversioned)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	path := writeConfig(t, ".pdk.yaml", unversioned)
+
+	result, err := Migrate(path, true)
+	if err != nil {
+		t.Fatalf("Migrate() returned an error: %v", err)
+	}
+
+	if got := readConfig(t, path); got != unversioned {
+		t.Errorf("the file was changed:\n%s", got)
+	}
+
+	if result.BackupFile != "" {
+		t.Errorf("got backup file %s, want none", result.BackupFile)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want only the config file", len(entries))
+	}
+
+	if !strings.Contains(result.Diff, "+config_version: 1") {
+		t.Errorf("the diff does not add config_version:\n%s", result.Diff)
+	}
+}
+
+func TestMigrateCurrentVersion(t *testing.T) {
+	content := "config_version: 1\nbackend: local\n"
+	path := writeConfig(t, ".pdk.yaml", content)
+
+	result, err := Migrate(path, false)
+	if err != nil {
+		t.Fatalf("Migrate() returned an error: %v", err)
+	}
+
+	if result.FromVersion != result.ToVersion || result.BackupFile != "" {
+		t.Errorf("got %+v, want no migration", result)
+	}
+
+	if got := readConfig(t, path); got != content {
+		t.Errorf("the file was changed:\n%s", got)
+	}
+}
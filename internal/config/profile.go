@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	profileKey  = "profile"
+	profilesKey = "profiles"
+)
+
+// applyProfile applies the values of the named profile on top of Config.
+// Keys that are overridden by an environment variable are left untouched.
+func applyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	profile := viper.Sub(profilesKey + "." + name)
+	if profile == nil {
+		return pdk_errors.New(pdk_errors.ProfileNotFound, "profile %q does not exist", name)
+	}
+
+	overlay := viper.New()
+	for _, key := range Keys() {
+		if !profile.IsSet(key) {
+			continue
+		}
+
+		if _, ok := os.LookupEnv(EnvVarName(key)); ok {
+			continue
+		}
+
+		overlay.Set(key, profile.Get(key))
+	}
+
+	if err := overlay.Unmarshal(&Config); err != nil {
+		return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "failed to unmarshal profile %q", name)
+	}
+
+	Config.Profile = name
+	log.Debug().Str("profile", name).Strs("keys", overlay.AllKeys()).Msg("applied config profile")
+
+	return nil
+}
+
+// Profiles returns the names of all profiles defined in the config file in
+// alphabetical order.
+func Profiles() []string {
+	var names []string
+	for name := range viper.GetStringMap(profilesKey) {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// UseProfile sets the default profile in the config file. Passing an empty
+// name clears the default profile.
+func UseProfile(name string) error {
+	if name != "" && !viper.IsSet(profilesKey+"."+name) {
+		return pdk_errors.New(pdk_errors.ProfileNotFound, "profile %q does not exist", name)
+	}
+
+	return Set(profileKey, name)
+}
+
+// CreateProfile adds a new profile to the config file with the given values.
+// Every key in values must be a valid configuration key. Values are stored
+// with the type of their key.
+func CreateProfile(name string, values map[string]string) error {
+	if name == "" || strings.Contains(name, ".") {
+		return pdk_errors.New(pdk_errors.Usage, "invalid profile name %q", name)
+	}
+
+	if viper.IsSet(profilesKey + "." + name) {
+		return pdk_errors.New(pdk_errors.Usage, "profile %q already exists", name)
+	}
+
+	profile := make(map[string]interface{})
+	for key, value := range values {
+		if !IsKey(key) {
+			return pdk_errors.New(pdk_errors.Usage, "invalid configuration key %q. Valid keys are: %s", key, strings.Join(Keys(), ", "))
+		}
+
+		parsed, err := ParseValue(key, value)
+		if err != nil {
+			return err
+		}
+		profile[key] = parsed
+	}
+
+	return Set(profilesKey+"."+name, profile)
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/spf13/viper"
+)
+
+// allowedValues holds the values that are accepted for keys that only
+// support a fixed set of values.
+var allowedValues = map[string][]string{
+	"always_build": {"false", "true"},
+	"backend":      {"docker", "local"},
+	"offline":      {"false", "true"},
+	"results_view": {"file", "terminal"},
+}
+
+// fileSchema describes the layout of a config file.
+type fileSchema struct {
+	config        `mapstructure:",squash"`
+	ConfigVersion int               `mapstructure:"config_version"`
+	Profile       string            `mapstructure:"profile"`
+	Profiles      map[string]config `mapstructure:"profiles"`
+}
+
+// AllowedValues returns the values that are accepted for the given key. It
+// returns nil if the key accepts any value of its type.
+func AllowedValues(key string) []string {
+	return allowedValues[key]
+}
+
+// Validate checks that data is a valid YAML config file. Unknown keys, values
+// of the wrong type and values that are not allowed are reported as errors.
+func Validate(data []byte) error {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewBuffer(data)); err != nil {
+		return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "invalid yaml")
+	}
+
+	var schema fileSchema
+	if err := v.UnmarshalExact(&schema); err != nil {
+		return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "the config does not match the config file layout")
+	}
+
+	if schema.ConfigVersion > CurrentVersion {
+		return pdk_errors.New(pdk_errors.InvalidConfig, "config_version %d is not supported. The latest version is %d", schema.ConfigVersion, CurrentVersion)
+	}
+
+	if err := validateValues(schema.config); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(schema.Profiles))
+	for name := range schema.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := validateValues(schema.Profiles[name]); err != nil {
+			return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "profile %q", name)
+		}
+	}
+
+	if schema.Profile != "" {
+		if _, ok := schema.Profiles[schema.Profile]; !ok {
+			return pdk_errors.New(pdk_errors.ProfileNotFound, "profile %q does not exist", schema.Profile)
+		}
+	}
+
+	return nil
+}
+
+// ValidateValue checks that value is allowed for the given key.
+func ValidateValue(key, value string) error {
+	allowed, ok := allowedValues[key]
+	if !ok || value == "" {
+		return nil
+	}
+
+	for _, a := range allowed {
+		if a == value {
+			return nil
+		}
+	}
+
+	return pdk_errors.New(pdk_errors.InvalidConfig, "invalid value %q for %s. Valid values are: %s", value, key, strings.Join(allowed, ", "))
+}
+
+// ParseValue converts value to the type of the given key and checks that it
+// is allowed, so that numbers and booleans are not written to the config
+// file as strings.
+func ParseValue(key, value string) (interface{}, error) {
+	if err := ValidateValue(key, value); err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("mapstructure") != key {
+			continue
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, pdk_errors.New(pdk_errors.InvalidConfig, "invalid value %q for %s. It must be true or false", value, key)
+			}
+			return b, nil
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, pdk_errors.New(pdk_errors.InvalidConfig, "invalid value %q for %s. It must be a whole number", value, key)
+			}
+			return n, nil
+		}
+	}
+
+	return value, nil
+}
+
+func validateValues(c config) error {
+	if err := ValidateValue("backend", c.Backend); err != nil {
+		return err
+	}
+
+	if err := ValidateValue("results_view", c.ResultsView); err != nil {
+		return err
+	}
+
+	if c.BuildTimeout < 0 {
+		return pdk_errors.New(pdk_errors.InvalidConfig, "build_timeout must not be negative")
+	}
+
+	if c.DownloadMaxSize < 0 {
+		return pdk_errors.New(pdk_errors.InvalidConfig, "download_max_size must not be negative")
+	}
+
+	if c.DownloadRetries < 0 {
+		return pdk_errors.New(pdk_errors.InvalidConfig, "download_retries must not be negative")
+	}
+
+	if c.HookTimeout < 0 {
+		return pdk_errors.New(pdk_errors.InvalidConfig, "hook_timeout must not be negative")
+	}
+
+	if c.InstallTimeout < 0 {
+		return pdk_errors.New(pdk_errors.InvalidConfig, "install_timeout must not be negative")
+	}
+
+	if c.ToolTimeout < 0 {
+		return pdk_errors.New(pdk_errors.InvalidConfig, "tool_timeout must not be negative")
+	}
+
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		return pdk_errors.New(pdk_errors.InvalidConfig, "client_cert and client_key must be set together")
+	}
+
+	for i, cred := range c.Credentials {
+		if err := cred.Validate(); err != nil {
+			return pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "credentials[%d]", i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// Package logging configures the global zerolog logger used by the pdk and the
+// libraries that it depends on.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/chelnak/pdk/internal/utils/terminal"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Options controls how the global logger is configured.
+type Options struct {
+	// Debug enables debug level logging.
+	Debug bool
+
+	// Format is the format of log lines. Valid values are 'text' and 'json'.
+	Format string
+
+	// File is the path of a file that log lines are appended to instead of
+	// stderr. Logging to a file enables debug level logging.
+	File string
+}
+
+var logFile *os.File
+
+// Init configures the global logger. Close should be called once the
+// application has finished to release the log file.
+func Init(opts Options) error {
+	var out io.Writer = os.Stderr
+
+	if opts.File != "" {
+		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600) // #nosec G304 -- path is provided by the user
+		if err != nil {
+			return fmt.Errorf("could not open log file: %s", err)
+		}
+
+		logFile = f
+		out = f
+	}
+
+	switch opts.Format {
+	case "", "text":
+		out = zerolog.ConsoleWriter{
+			Out:        out,
+			NoColor:    opts.File != "" || !terminal.IsTTY(),
+			TimeFormat: time.RFC3339,
+		}
+	case "json":
+	default:
+		return fmt.Errorf("invalid log format %q. Valid values are 'text' and 'json'", opts.Format)
+	}
+
+	level := zerolog.WarnLevel
+	if opts.Debug || opts.File != "" {
+		level = zerolog.DebugLevel
+	}
+
+	zerolog.SetGlobalLevel(level)
+	zerolog.DurationFieldUnit = time.Millisecond
+	log.Logger = zerolog.New(out).With().Timestamp().Logger()
+
+	return nil
+}
+
+// Close closes the log file if one was opened by Init.
+func Close() error {
+	if logFile == nil {
+		return nil
+	}
+
+	err := logFile.Close()
+	logFile = nil
+	return err
+}
+
+// Transport is an http.RoundTripper that logs every request it sends.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// NewTransport returns a Transport that wraps base. If base is nil
+// http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Transport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+
+	event := log.Debug().
+		Str("method", req.Method).
+		Str("url", req.URL.Redacted()).
+		Dur("duration", time.Since(start))
+
+	if err != nil {
+		event.Err(err).Msg("http request failed")
+		return resp, err
+	}
+
+	event.Int("status", resp.StatusCode).Int64("content_length", resp.ContentLength).Msg("http request")
+	return resp, nil
+}
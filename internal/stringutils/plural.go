@@ -0,0 +1,67 @@
+package stringutils
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	// irregularPlurals are the plurals that the suffix rules in plural get
+	// wrong.
+	irregularPlurals = map[string]string{
+		"child": "children", "person": "people", "man": "men", "woman": "women", "mouse": "mice",
+		"goose": "geese", "foot": "feet", "tooth": "teeth", "index": "indices", "matrix": "matrices",
+		"criterion": "criteria", "datum": "data", "schema": "schemas", "calf": "calves", "elf": "elves",
+		"half": "halves", "hoof": "hooves", "knife": "knives", "leaf": "leaves", "life": "lives", "loaf": "loaves",
+		"scarf": "scarves", "self": "selves", "shelf": "shelves", "thief": "thieves", "wife": "wives", "wolf": "wolves",
+	}
+
+	// uncountable words are the same in the singular and the plural.
+	uncountable = map[string]bool{
+		"data": true, "information": true, "metadata": true, "series": true, "species": true, "sheep": true,
+		"fish": true, "deer": true, "equipment": true, "news": true,
+	}
+)
+
+// Plural returns the English plural of word.
+func Plural(word string) string {
+	lower := strings.ToLower(word)
+	if uncountable[lower] {
+		return word
+	}
+
+	if plural, ok := irregularPlurals[lower]; ok {
+		if word != lower {
+			return Capitalise(plural)
+		}
+		return plural
+	}
+
+	switch {
+	case hasAnySuffix(lower, "s", "x", "z", "ch", "sh"):
+		return word + "es"
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return word[:len(word)-1] + "ies"
+	}
+
+	return word + "s"
+}
+
+// Pluralize returns word when count is one and its plural otherwise.
+func Pluralize(count interface{}, word string) string {
+	if fmt.Sprint(count) == "1" {
+		return word
+	}
+
+	return Plural(word)
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
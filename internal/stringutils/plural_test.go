@@ -0,0 +1,61 @@
+package stringutils
+
+import "testing"
+
+func TestPlural(t *testing.T) {
+	tests := map[string]string{
+		"server":  "servers",
+		"class":   "classes",
+		"box":     "boxes",
+		"branch":  "branches",
+		"policy":  "policies",
+		"key":     "keys",
+		"roof":    "roofs",
+		"proof":   "proofs",
+		"chef":    "chefs",
+		"cafe":    "cafes",
+		"giraffe": "giraffes",
+		"leaf":    "leaves",
+		"half":    "halves",
+		"wolf":    "wolves",
+		"knife":   "knives",
+		"child":   "children",
+		"Person":  "People",
+		"data":    "data",
+		"Sheep":   "Sheep",
+	}
+
+	for word, want := range tests {
+		if got := Plural(word); got != want {
+			t.Errorf("Plural(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		count interface{}
+		want  string
+	}{
+		{count: 0, want: "leaves"},
+		{count: 1, want: "leaf"},
+		{count: "1", want: "leaf"},
+		{count: 2, want: "leaves"},
+	}
+
+	for _, tt := range tests {
+		if got := Pluralize(tt.count, "leaf"); got != tt.want {
+			t.Errorf("Pluralize(%v, \"leaf\") = %q, want %q", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestCapitalise(t *testing.T) {
+	tests := map[string]string{"": "", "web": "Web", "Web": "Web", "élan": "Élan"}
+
+	for word, want := range tests {
+		if got := Capitalise(word); got != want {
+			t.Errorf("Capitalise(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 // Package stringutils contains utility functions for working with strings.
 package stringutils
 
-import "regexp"
+import (
+	"regexp"
+	"unicode"
+)
 
-// IsGitURL returns true if the given string is a valid git uri.
+// IsGitURL returns true if the given string is a valid git uri. The uri may
+// end with a #ref suffix naming a branch, tag or commit.
 func IsGitURL(s string) bool {
-	pattern := "^(?:git|ssh|git|http|https@|)(?:.*)(?:.*)(?:.git)$"
+	pattern := "^(?:git|ssh|git|http|https@|)(?:.*)(?:.*)(?:.git)(?:#[^#]+)?$"
 	reg := regexp.MustCompile(pattern)
 	return reg.MatchString(s)
 }
@@ -16,3 +20,23 @@ func IsTarGZ(s string) bool {
 	reg := regexp.MustCompile(pattern)
 	return reg.MatchString(s)
 }
+
+// IsHTTPURL returns true if the given string is an http or https url. The
+// url does not need to end in .tar.gz as the package name may come from a
+// redirect or the Content-Disposition header.
+func IsHTTPURL(s string) bool {
+	pattern := "^https?://"
+	reg := regexp.MustCompile(pattern)
+	return reg.MatchString(s)
+}
+
+// Capitalise returns word with its first letter in upper case.
+func Capitalise(word string) string {
+	if word == "" {
+		return word
+	}
+
+	runes := []rune(word)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,120 @@
+// Package tools finds the tool packages that are installed in the configured
+// tool path and runs them against content for 'pdk exec' and 'pdk validate'.
+package tools
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/pkg/exec_runner"
+	"github.com/chelnak/pdk/pkg/install"
+	"github.com/chelnak/pdk/pkg/pct_config_processor"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/tool"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// Installed is a tool package in the tool path.
+type Installed struct {
+	install.InstalledPackage
+	Info pct_config_processor.PuppetContentTemplateInfo
+}
+
+// List returns the highest version of every tool that is installed in the
+// tool path. Packages in the tool path that are not tools are left out.
+func List() ([]Installed, error) {
+	packages, err := install.List(config.ToolPath())
+	if err != nil {
+		return nil, err
+	}
+
+	var tools []Installed
+	seen := map[string]bool{}
+	for _, p := range packages {
+		if seen[p.Name()] {
+			continue
+		}
+		seen[p.Name()] = true
+
+		latest, _ := install.Find(packages, p.Name())
+		t, err := read(latest)
+		if err != nil {
+			log.Debug().Err(err).Str("path", latest.Path).Msg("skipping package")
+			continue
+		}
+
+		tools = append(tools, t)
+	}
+
+	return tools, nil
+}
+
+// Find returns the tool named name, in the form author/id or
+// author/id/version.
+func Find(name string) (Installed, error) {
+	packages, err := install.List(config.ToolPath())
+	if err != nil {
+		return Installed{}, err
+	}
+
+	pkg, ok := install.Find(packages, name)
+	if !ok {
+		return Installed{}, pdk_errors.New(pdk_errors.NotFound, "tool %s is not installed in %s", name, config.ToolPath())
+	}
+
+	return read(pkg)
+}
+
+func read(pkg install.InstalledPackage) (Installed, error) {
+	processor := &pct_config_processor.PctConfigProcessor{AFS: &afero.Afero{Fs: afero.NewOsFs()}}
+	info, err := processor.ReadConfig(filepath.Join(pkg.Path, "pct-config.yml"))
+	if err != nil {
+		return Installed{}, pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "could not read the config of %s", pkg.Name())
+	}
+
+	if info.Template.Kind() != pct_config_processor.Tool {
+		return Installed{}, pdk_errors.New(pdk_errors.InvalidTemplate, "%s is a %s template, not a tool", pkg.Name(), info.Template.Kind())
+	}
+
+	return Installed{InstalledPackage: pkg, Info: info}, nil
+}
+
+// ResultsFile is the file in the code directory that results are written to
+// when results_view is file.
+const ResultsFile = "pdk-results.json"
+
+// Dir returns the directory that tools run against: code_dir, or the current
+// working directory if it is not set.
+func Dir() (string, error) {
+	dir := config.Config.CodeDir
+	if dir == "" {
+		dir = "."
+	}
+
+	return filepath.Abs(dir)
+}
+
+// Run runs t against the content in Dir with the configured backend, puppet
+// version and timeout. capability may be empty. tool_args are passed to the
+// tool before args.
+func Run(ctx context.Context, t Installed, capability tool.Capability, args []string, stdout, stderr io.Writer) (exec_runner.Result, error) {
+	dir, err := Dir()
+	if err != nil {
+		return exec_runner.Result{}, err
+	}
+
+	return tool.NewRunner().Run(ctx, t.Name(), t.Path, t.Info.Tool, tool.Options{
+		Dir:           dir,
+		Capability:    capability,
+		Args:          append(strings.Fields(config.Config.ToolArgs), args...),
+		Backend:       config.Config.Backend,
+		PuppetVersion: config.Config.PuppetVersion,
+		Stdout:        stdout,
+		Stderr:        stderr,
+		Timeout:       config.Timeout(config.Config.ToolTimeout),
+	})
+}
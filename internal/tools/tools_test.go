@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/chelnak/pdk/internal/config"
+	"github.com/chelnak/pdk/pkg/install"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/tool"
+)
+
+// copyDir copies the files in src to dst, keeping their modes.
+func copyDir(t *testing.T, src, dst string) {
+	t.Helper()
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0750)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(target, content, info.Mode().Perm())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunStubTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub tool is a shell script")
+	}
+
+	saved := config.Config
+	t.Cleanup(func() { config.Config = saved })
+
+	// InstallFromConfig moves the package, so install a copy of it.
+	src := filepath.Join(t.TempDir(), "stub")
+	copyDir(t, filepath.Join("..", "..", "testdata", "tools", "stub"), src)
+
+	code := t.TempDir()
+	manifest := "class example {\n  # FIXME: add resources \n}\n"
+	if err := os.WriteFile(filepath.Join(code, "init.pp"), []byte(manifest), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Config.ToolPath = filepath.Join(t.TempDir(), "tools")
+	config.Config.CodeDir = code
+	config.Config.Backend = tool.Local
+	config.Config.PuppetVersion = "7.14.0"
+
+	installer := install.NewInstaller(install.Options{ToolPath: config.Config.ToolPath})
+	path, err := installer.InstallFromConfig(filepath.Join(src, "pct-config.yml"), t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("InstallFromConfig() returned an error: %v", err)
+	}
+
+	if want := filepath.Join(config.Config.ToolPath, "pdk", "stub", "0.1.0"); path != want {
+		t.Errorf("installed to %s, want %s", path, want)
+	}
+
+	stub, err := Find("pdk/stub")
+	if err != nil {
+		t.Fatalf("Find() returned an error: %v", err)
+	}
+
+	result, err := Run(context.Background(), stub, tool.Validate, nil, nil, nil)
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.Tool {
+		t.Errorf("got kind %s, want %s: %v", kind.Code, pdk_errors.Tool.Code, err)
+	}
+	if result.ExitCode != 1 {
+		t.Errorf("got exit code %d, want 1", result.ExitCode)
+	}
+
+	results, err := stub.Info.Tool.Output.Parse(result.Stdout, result.Stderr)
+	if err != nil {
+		t.Fatalf("Parse() returned an error: %v", err)
+	}
+
+	want := []tool.Result{
+		{File: "init.pp", Line: 2, Column: 1, Severity: "warning", Rule: "trailing_whitespace", Message: "trailing whitespace"},
+		{File: "init.pp", Line: 2, Column: 1, Severity: "error", Rule: "fixme", Message: "unresolved FIXME"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got results\n%+v\nwant\n%+v", results, want)
+	}
+}
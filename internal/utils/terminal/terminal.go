@@ -7,7 +7,17 @@ import (
 
 // IsTTY returns true if the terminal is a TTY.
 func IsTTY() bool {
-	fi, err := os.Stdout.Stat()
+	return isCharDevice(os.Stdout)
+}
+
+// IsInteractive returns true if both stdin and stdout are attached to a
+// terminal, so that questions can be asked and answered.
+func IsInteractive() bool {
+	return isCharDevice(os.Stdin) && isCharDevice(os.Stdout)
+}
+
+func isCharDevice(f *os.File) bool {
+	fi, err := f.Stat()
 	if err != nil {
 		return false
 	}
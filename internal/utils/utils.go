@@ -0,0 +1,13 @@
+// Package utils contains small helpers that are shared by commands.
+package utils
+
+// Contains returns true if value is one of values.
+func Contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
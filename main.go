@@ -1,8 +1,12 @@
 // Package main is the entry point for the application.
 package main
 
-import "github.com/chelnak/pdk/cmd"
+import (
+	"os"
+
+	"github.com/chelnak/pdk/cmd"
+)
 
 func main() {
-	cmd.Execute()
+	os.Exit(cmd.Execute())
 }
@@ -2,19 +2,22 @@
 package build
 
 import (
-	"fmt"
+	"context"
 	"os"
 	"path/filepath"
 
 	"github.com/chelnak/pdk/pkg/pct_config_processor"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
 	"github.com/puppetlabs/pct/pkg/config_processor"
 	"github.com/puppetlabs/pct/pkg/gzip"
 	"github.com/puppetlabs/pct/pkg/tar"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/afero"
 )
 
 type Builder interface {
-	Build(source, target string) (archivePath string, err error)
+	Build(ctx context.Context, source, target string) (archivePath string, err error)
+	Archive(ctx context.Context, source, target string) (archivePath string, err error)
 }
 
 type builder struct {
@@ -25,59 +28,76 @@ type builder struct {
 	ConfigFile      string
 }
 
-func (b *builder) Build(source, target string) (archivePath string, err error) {
+func (b *builder) Build(ctx context.Context, source, target string) (archivePath string, err error) {
+	log.Debug().Str("source", source).Str("target", target).Msg("building package")
+
 	if err := b.validateProjectStructure(source); err != nil {
 		return archivePath, err
 	}
 
 	if err := b.ConfigProcessor.CheckConfig(filepath.Join(source, b.ConfigFile)); err != nil {
-		return archivePath, fmt.Errorf("invalid config: %v", err.Error())
+		return archivePath, pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "invalid config")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return archivePath, err
 	}
 
-	return b.makeArchive(source, target)
+	return b.Archive(ctx, source, target)
 }
 
 func (b *builder) validateProjectStructure(source string) error {
 	// Check project dir exists
 	if _, err := b.AFS.Stat(source); os.IsNotExist(err) {
-		return fmt.Errorf("no project directory at %v", source)
+		return pdk_errors.New(pdk_errors.NotFound, "no project directory at %v", source)
 	}
 
 	// Check if config file exists
 	if _, err := b.AFS.Stat(filepath.Join(source, b.ConfigFile)); os.IsNotExist(err) {
-		return fmt.Errorf("no '%v' found in %v", b.ConfigFile, source)
+		return pdk_errors.New(pdk_errors.InvalidTemplate, "no '%v' found in %v", b.ConfigFile, source)
 	}
 
-	// Check if content dir exists
-	if _, err := b.AFS.Stat(filepath.Join(source, "content")); os.IsNotExist(err) {
-		return fmt.Errorf("no 'content' dir found in %v", source)
-	}
+	// The rest of the layout depends on the type of the template and is
+	// checked by CheckConfig.
 
 	return nil
 }
 
-func (b *builder) makeArchive(source, target string) (string, error) {
-	var archivePath string
-
+// Archive packs the source directory in to a tar.gz in the target directory
+// without validating it as a template. The archive is named after source.
+// Files that match the patterns in the .pdkignore file of source are left
+// out.
+func (b *builder) Archive(ctx context.Context, source, target string) (archivePath string, err error) {
 	tempDir, err := b.AFS.TempDir("", "")
 	if err != nil {
-		return archivePath, fmt.Errorf("could not create tempdir: %v", err)
+		return archivePath, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create tempdir")
 	}
 
 	defer func() {
 		if cleanErr := os.RemoveAll(tempDir); cleanErr != nil && err == nil {
-			err = fmt.Errorf("error cleaning up temp dir: %v", cleanErr)
+			err = pdk_errors.Wrap(pdk_errors.FileSystem, cleanErr, "error cleaning up temp dir")
 		}
 	}()
 
+	source, err = b.stage(source, tempDir)
+	if err != nil {
+		return archivePath, err
+	}
+
+	log.Debug().Str("source", source).Str("dir", tempDir).Msg("creating tar archive")
 	tar, err := b.Tar.Tar(source, tempDir)
 	if err != nil {
-		return archivePath, fmt.Errorf("could not TAR project (%v): %v", source, err)
+		return archivePath, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not TAR project (%v)", source)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return archivePath, err
 	}
 
+	log.Debug().Str("tar", tar).Str("target", target).Msg("compressing archive")
 	archivePath, err = b.Gzip.Gzip(tar, target)
 	if err != nil {
-		return archivePath, fmt.Errorf("could not GZIP project (%v): %v", tar, err)
+		return archivePath, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not GZIP project (%v)", tar)
 	}
 
 	return archivePath, nil
@@ -0,0 +1,94 @@
+package build
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// IgnoreFile lists the files in a project that are left out of its package.
+// It uses the same syntax as .gitignore.
+const IgnoreFile = ".pdkignore"
+
+// readIgnoreFile returns a matcher for the patterns in the ignore file of
+// source, or nil if it does not have one.
+func readIgnoreFile(afs *afero.Afero, source string) (gitignore.Matcher, error) {
+	data, err := afs.ReadFile(filepath.Join(source, IgnoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		patterns = append(patterns, gitignore.ParsePattern(line, nil))
+	}
+
+	return gitignore.NewMatcher(patterns), scanner.Err()
+}
+
+// stage copies the files of source that are not ignored in to a directory
+// with the same name under tempDir and returns its path. source is returned
+// unchanged if it has no ignore file.
+func (b *builder) stage(source, tempDir string) (string, error) {
+	matcher, err := readIgnoreFile(b.AFS, source)
+	if err != nil {
+		return "", pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not read %s", IgnoreFile)
+	}
+
+	if matcher == nil {
+		return source, nil
+	}
+
+	staged := filepath.Join(tempDir, "stage", filepath.Base(source))
+	err = b.AFS.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+
+		if rel != "." && matcher.Match(strings.Split(filepath.ToSlash(rel), "/"), info.IsDir()) {
+			log.Debug().Str("path", rel).Msg("ignoring path")
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		dest := filepath.Join(staged, rel)
+		if info.IsDir() {
+			return b.AFS.MkdirAll(dest, info.Mode().Perm()|0700)
+		}
+
+		content, err := b.AFS.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return b.AFS.WriteFile(dest, content, info.Mode().Perm())
+	})
+	if err != nil {
+		return "", pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not copy %s", source)
+	}
+
+	return staged, nil
+}
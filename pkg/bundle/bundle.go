@@ -0,0 +1,490 @@
+// Package bundle packs installed templates, tools and runtime images in to a
+// single archive that can be carried to a machine without network access
+// and installed there.
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/build"
+	"github.com/chelnak/pdk/pkg/exec_runner"
+	"github.com/chelnak/pdk/pkg/install"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/puppetlabs/pct/pkg/gzip"
+	"github.com/puppetlabs/pct/pkg/tar"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ManifestFile is the name of the manifest at the root of a bundle.
+	ManifestFile = "bundle.yml"
+
+	// ManifestVersion is the version of the manifest format written by
+	// Export.
+	ManifestVersion = 1
+
+	// KindTemplate and KindTool are the kinds of package in a bundle.
+	KindTemplate = "template"
+	KindTool     = "tool"
+)
+
+var imageNamePattern = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// Manifest describes the contents of a bundle.
+type Manifest struct {
+	Version  int       `yaml:"version"`
+	Created  time.Time `yaml:"created"`
+	Packages []Package `yaml:"packages"`
+	Images   []Image   `yaml:"images,omitempty"`
+}
+
+// Package is a template or tool in a bundle.
+type Package struct {
+	Kind    string `yaml:"kind"`
+	Author  string `yaml:"author"`
+	ID      string `yaml:"id"`
+	Version string `yaml:"version"`
+	// Path is the directory of the package relative to the bundle root.
+	Path string `yaml:"path"`
+	// Checksum is the sha256 of every file in the package.
+	Checksum string `yaml:"checksum"`
+}
+
+// Name returns the namespaced name of the package in the form author/id.
+func (p Package) Name() string {
+	return fmt.Sprintf("%s/%s", p.Author, p.ID)
+}
+
+// Image is a runtime image saved in a bundle.
+type Image struct {
+	Name string `yaml:"name"`
+	// Path is the image archive relative to the bundle root.
+	Path     string `yaml:"path"`
+	Checksum string `yaml:"checksum"`
+}
+
+// ExportOptions controls what is exported.
+type ExportOptions struct {
+	// Name is the name of the bundle. The archive is written to
+	// TargetDir/Name.tar.gz.
+	Name      string
+	TargetDir string
+
+	Templates []install.InstalledPackage
+	Tools     []install.InstalledPackage
+
+	// Images are saved with 'docker save' when they are set.
+	Images []string
+}
+
+// ImportOptions controls where a bundle is imported to.
+type ImportOptions struct {
+	TemplatePath string
+	ToolPath     string
+
+	// Force replaces packages that are already installed. Without it they
+	// are skipped.
+	Force bool
+
+	// SkipImages skips loading the runtime images in the bundle.
+	SkipImages bool
+}
+
+// ImportResult describes what was imported.
+type ImportResult struct {
+	Installed []string
+	Skipped   []string
+	Images    []string
+}
+
+// Bundler exports and imports bundles.
+type Bundler interface {
+	Export(ctx context.Context, opts ExportOptions) (archivePath string, err error)
+	Import(ctx context.Context, archive string, opts ImportOptions) (ImportResult, error)
+}
+
+type bundler struct {
+	Tar       tar.TarI
+	Gunzip    gzip.GunzipI
+	AFS       *afero.Afero
+	Builder   build.Builder
+	Installer install.Installer
+	Exec      exec_runner.ExecRunner
+}
+
+// Export copies the packages and images in opts in to a staging directory,
+// writes a manifest with their checksums and archives the result.
+func (b *bundler) Export(ctx context.Context, opts ExportOptions) (archivePath string, err error) {
+	if strings.ContainsAny(opts.Name, `./\`) || opts.Name == "" {
+		return "", pdk_errors.New(pdk_errors.Usage, "invalid bundle name %q. Names must not be empty or contain dots or slashes", opts.Name)
+	}
+
+	stagingDir, err := b.AFS.TempDir("", "")
+	if err != nil {
+		return "", pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create tempdir")
+	}
+
+	defer func() {
+		if cleanErr := b.AFS.RemoveAll(stagingDir); cleanErr != nil && err == nil {
+			err = pdk_errors.Wrap(pdk_errors.FileSystem, cleanErr, "error cleaning up temp dir")
+		}
+	}()
+
+	root := filepath.Join(stagingDir, opts.Name)
+	manifest := Manifest{Version: ManifestVersion, Created: time.Now().UTC()}
+
+	for kind, packages := range map[string][]install.InstalledPackage{KindTemplate: opts.Templates, KindTool: opts.Tools} {
+		for _, p := range packages {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+
+			pkg, err := b.addPackage(root, kind, p)
+			if err != nil {
+				return "", err
+			}
+
+			manifest.Packages = append(manifest.Packages, pkg)
+		}
+	}
+
+	sortPackages(manifest.Packages)
+
+	for _, name := range opts.Images {
+		image, err := b.addImage(ctx, root, name)
+		if err != nil {
+			return "", err
+		}
+
+		manifest.Images = append(manifest.Images, image)
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	if err := b.AFS.WriteFile(filepath.Join(root, ManifestFile), data, 0640); err != nil {
+		return "", pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not write bundle manifest")
+	}
+
+	return b.Builder.Archive(ctx, root, opts.TargetDir)
+}
+
+func (b *bundler) addPackage(root, kind string, p install.InstalledPackage) (Package, error) {
+	rel := filepath.Join(kind+"s", p.Author, p.ID, p.Version)
+
+	log.Debug().Str("kind", kind).Str("package", p.Name()).Str("version", p.Version).Msg("adding package to bundle")
+
+	if err := copyDir(b.AFS, p.Path, filepath.Join(root, rel)); err != nil {
+		return Package{}, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not copy %s", p.Path)
+	}
+
+	checksum, err := dirChecksum(b.AFS, filepath.Join(root, rel))
+	if err != nil {
+		return Package{}, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not checksum %s", p.Path)
+	}
+
+	return Package{
+		Kind:     kind,
+		Author:   p.Author,
+		ID:       p.ID,
+		Version:  p.Version,
+		Path:     filepath.ToSlash(rel),
+		Checksum: checksum,
+	}, nil
+}
+
+func (b *bundler) addImage(ctx context.Context, root, name string) (Image, error) {
+	rel := filepath.Join("images", imageNamePattern.ReplaceAllString(name, "_")+".tar")
+	path := filepath.Join(root, rel)
+
+	if err := b.AFS.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return Image{}, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create images directory")
+	}
+
+	log.Debug().Str("image", name).Str("path", path).Msg("saving image to bundle")
+
+	if _, err := b.Exec.Run(ctx, "docker", []string{"save", "--output", path, name}, exec_runner.Options{}); err != nil {
+		return Image{}, pdk_errors.Wrap(pdk_errors.KindOf(err), err, "could not save image %s", name)
+	}
+
+	checksum, err := fileChecksum(b.AFS, path)
+	if err != nil {
+		return Image{}, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not checksum image %s", name)
+	}
+
+	return Image{Name: name, Path: filepath.ToSlash(rel), Checksum: checksum}, nil
+}
+
+// Import extracts a bundle, verifies every checksum in its manifest and
+// then installs its packages and loads its images. Nothing is installed if
+// any checksum does not match.
+func (b *bundler) Import(ctx context.Context, archive string, opts ImportOptions) (result ImportResult, err error) {
+	log.Debug().Str("bundle", archive).Msg("importing bundle")
+
+	if _, err := b.AFS.Stat(archive); os.IsNotExist(err) {
+		return result, pdk_errors.New(pdk_errors.NotFound, "no bundle at %v", archive)
+	}
+
+	tempDir, err := b.AFS.TempDir("", "")
+	if err != nil {
+		return result, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create tempdir")
+	}
+
+	defer func() {
+		if cleanErr := b.AFS.RemoveAll(tempDir); cleanErr != nil && err == nil {
+			err = pdk_errors.Wrap(pdk_errors.FileSystem, cleanErr, "error cleaning up temp dir")
+		}
+	}()
+
+	tarfile, err := b.Gunzip.Gunzip(archive, tempDir)
+	if err != nil {
+		return result, pdk_errors.Wrap(pdk_errors.InvalidPackage, err, "could not extract TAR from GZIP (%v)", archive)
+	}
+
+	root, err := b.Tar.Untar(tarfile, tempDir)
+	if err != nil {
+		return result, pdk_errors.Wrap(pdk_errors.InvalidPackage, err, "could not UNTAR bundle (%v)", archive)
+	}
+
+	manifest, err := b.readManifest(root)
+	if err != nil {
+		return result, err
+	}
+
+	if err := b.verify(root, manifest); err != nil {
+		return result, err
+	}
+
+	for _, p := range manifest.Packages {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		target := opts.TemplatePath
+		if p.Kind == KindTool {
+			target = opts.ToolPath
+		}
+
+		path, err := b.Installer.InstallFromConfig(filepath.Join(root, filepath.FromSlash(p.Path), "pct-config.yml"), target, opts.Force)
+		if pdk_errors.KindOf(err) == pdk_errors.AlreadyInstalled {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s/%s", p.Name(), p.Version))
+			continue
+		}
+
+		if err != nil {
+			return result, err
+		}
+
+		result.Installed = append(result.Installed, path)
+	}
+
+	if opts.SkipImages {
+		return result, nil
+	}
+
+	for _, image := range manifest.Images {
+		path := filepath.Join(root, filepath.FromSlash(image.Path))
+		if _, err := b.Exec.Run(ctx, "docker", []string{"load", "--input", path}, exec_runner.Options{}); err != nil {
+			return result, pdk_errors.Wrap(pdk_errors.KindOf(err), err, "could not load image %s", image.Name)
+		}
+
+		result.Images = append(result.Images, image.Name)
+	}
+
+	return result, nil
+}
+
+func (b *bundler) readManifest(root string) (Manifest, error) {
+	var manifest Manifest
+
+	data, err := b.AFS.ReadFile(filepath.Join(root, ManifestFile))
+	if err != nil {
+		return manifest, pdk_errors.Wrap(pdk_errors.InvalidPackage, err, "bundle does not contain %s", ManifestFile)
+	}
+
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return manifest, pdk_errors.Wrap(pdk_errors.InvalidPackage, err, "invalid bundle manifest")
+	}
+
+	if manifest.Version > ManifestVersion {
+		return manifest, pdk_errors.New(pdk_errors.InvalidPackage, "bundle manifest version %d is not supported. The latest version is %d", manifest.Version, ManifestVersion)
+	}
+
+	return manifest, nil
+}
+
+// verify checks every package and image in manifest against its checksum.
+func (b *bundler) verify(root string, manifest Manifest) error {
+	for _, p := range manifest.Packages {
+		if p.Kind != KindTemplate && p.Kind != KindTool {
+			return pdk_errors.New(pdk_errors.InvalidPackage, "unknown package kind %q for %s", p.Kind, p.Name())
+		}
+
+		path, err := bundlePath(root, p.Path)
+		if err != nil {
+			return err
+		}
+
+		checksum, err := dirChecksum(b.AFS, path)
+		if err != nil {
+			return pdk_errors.Wrap(pdk_errors.InvalidPackage, err, "could not checksum %s", p.Path)
+		}
+
+		if checksum != p.Checksum {
+			return pdk_errors.New(pdk_errors.InvalidPackage, "checksum mismatch for %s/%s: expected %s, got %s", p.Name(), p.Version, p.Checksum, checksum)
+		}
+	}
+
+	for _, image := range manifest.Images {
+		path, err := bundlePath(root, image.Path)
+		if err != nil {
+			return err
+		}
+
+		checksum, err := fileChecksum(b.AFS, path)
+		if err != nil {
+			return pdk_errors.Wrap(pdk_errors.InvalidPackage, err, "could not checksum image %s", image.Name)
+		}
+
+		if checksum != image.Checksum {
+			return pdk_errors.New(pdk_errors.InvalidPackage, "checksum mismatch for image %s: expected %s, got %s", image.Name, image.Checksum, checksum)
+		}
+	}
+
+	return nil
+}
+
+// bundlePath resolves a path from the manifest and makes sure that it is
+// relative and does not point outside of the bundle.
+func bundlePath(root, rel string) (string, error) {
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if strings.HasPrefix(rel, "/") || filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" ||
+		!strings.HasPrefix(path, filepath.Clean(root)+string(filepath.Separator)) {
+		return "", pdk_errors.New(pdk_errors.InvalidPackage, "bundle manifest path %q is outside of the bundle", rel)
+	}
+
+	return path, nil
+}
+
+// dirChecksum returns the sha256 of the relative path and content of every
+// file in dir, in lexical order.
+func dirChecksum(afs *afero.Afero, dir string) (string, error) {
+	h := sha256.New()
+
+	err := afs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		sum, err := fileChecksum(afs, path)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(h, "%s %s\n", sum, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func fileChecksum(afs *afero.Afero, path string) (string, error) {
+	file, err := afs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close() // nolint
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyDir copies the files in source to target, creating target if needed.
+func copyDir(afs *afero.Afero, source, target string) error {
+	return afs.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+
+		dest := filepath.Join(target, rel)
+		if info.IsDir() {
+			return afs.MkdirAll(dest, 0750)
+		}
+
+		in, err := afs.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close() // nolint
+
+		out, err := afs.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(out, in); err != nil {
+			_ = out.Close()
+			return err
+		}
+
+		return out.Close()
+	})
+}
+
+func sortPackages(packages []Package) {
+	sort.Slice(packages, func(i, j int) bool {
+		a, b := packages[i], packages[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name() != b.Name() {
+			return a.Name() < b.Name()
+		}
+		return a.Version < b.Version
+	})
+}
+
+// NewBundler returns a Bundler that works on the local file system.
+func NewBundler() Bundler {
+	fs := afero.NewOsFs()
+
+	return &bundler{
+		Tar:       &tar.Tar{AFS: &afero.Afero{Fs: fs}},
+		Gunzip:    &gzip.Gunzip{AFS: &afero.Afero{Fs: fs}},
+		AFS:       &afero.Afero{Fs: fs},
+		Builder:   build.NewBuilder(),
+		Installer: install.NewInstaller(install.Options{}),
+		Exec:      exec_runner.NewExecRunner(),
+	}
+}
@@ -0,0 +1,322 @@
+package bundle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chelnak/pdk/pkg/build"
+	"github.com/chelnak/pdk/pkg/exec_runner"
+	"github.com/chelnak/pdk/pkg/install"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/puppetlabs/pct/pkg/gzip"
+	"github.com/puppetlabs/pct/pkg/tar"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v2"
+)
+
+const templateConfig = `template:
+  id: demo
+  author: pdk
+  version: 0.1.0
+  type: item
+`
+
+// newTestBundler returns a bundler that works on the local file system and
+// runs docker with runner.
+func newTestBundler(runner exec_runner.ExecRunner) *bundler {
+	afs := &afero.Afero{Fs: afero.NewOsFs()}
+
+	return &bundler{
+		Tar:       &tar.Tar{AFS: afs},
+		Gunzip:    &gzip.Gunzip{AFS: afs},
+		AFS:       afs,
+		Builder:   build.NewBuilder(),
+		Installer: install.NewInstaller(install.Options{}),
+		Exec:      runner,
+	}
+}
+
+// fakeDocker saves images by writing their name to the output file and
+// accepts every load.
+func fakeDocker() *exec_runner.FakeExecRunner {
+	return &exec_runner.FakeExecRunner{
+		Handler: func(ctx context.Context, call exec_runner.FakeCall) (exec_runner.Result, error) {
+			if call.Args[0] == "save" {
+				return exec_runner.Result{}, os.WriteFile(call.Args[2], []byte(call.Args[3]), 0600)
+			}
+
+			return exec_runner.Result{}, nil
+		},
+	}
+}
+
+// installedTemplate writes the pdk/demo/0.1.0 template to a template path.
+func installedTemplate(t *testing.T) install.InstalledPackage {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "pdk", "demo", "0.1.0")
+	writeFile(t, filepath.Join(path, "pct-config.yml"), templateConfig)
+	writeFile(t, filepath.Join(path, "content", "demo.txt.tmpl"), "demo")
+
+	return install.InstalledPackage{Author: "pdk", ID: "demo", Version: "0.1.0", Path: path}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// export creates a bundle holding the demo template and an image.
+func export(t *testing.T) string {
+	t.Helper()
+
+	archive, err := newTestBundler(fakeDocker()).Export(context.Background(), ExportOptions{
+		Name:      "offline",
+		TargetDir: t.TempDir(),
+		Templates: []install.InstalledPackage{installedTemplate(t)},
+		Images:    []string{"puppet/pdk:latest"},
+	})
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	return archive
+}
+
+// extract extracts archive and returns the bundle root.
+func extract(t *testing.T, archive string) string {
+	t.Helper()
+
+	b := newTestBundler(nil)
+	dir := t.TempDir()
+
+	tarfile, err := b.Gunzip.Gunzip(archive, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := b.Tar.Untar(tarfile, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+// repack extracts archive, calls edit with the bundle root and archives the
+// result again.
+func repack(t *testing.T, archive string, edit func(t *testing.T, root string)) string {
+	t.Helper()
+
+	root := extract(t, archive)
+	edit(t, root)
+
+	repacked, err := build.NewBuilder().Archive(context.Background(), root, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repacked
+}
+
+func TestExportImport(t *testing.T) {
+	archive := export(t)
+
+	runner := fakeDocker()
+	templatePath := t.TempDir()
+	opts := ImportOptions{TemplatePath: templatePath, ToolPath: t.TempDir()}
+
+	result, err := newTestBundler(runner).Import(context.Background(), archive, opts)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	installed := filepath.Join(templatePath, "pdk", "demo", "0.1.0")
+	if len(result.Installed) != 1 || result.Installed[0] != installed {
+		t.Errorf("Installed = %v, want [%s]", result.Installed, installed)
+	}
+
+	content, err := os.ReadFile(filepath.Join(installed, "content", "demo.txt.tmpl"))
+	if err != nil || string(content) != "demo" {
+		t.Errorf("got installed content %q (%v), want %q", content, err, "demo")
+	}
+
+	if len(result.Images) != 1 || result.Images[0] != "puppet/pdk:latest" {
+		t.Errorf("Images = %v, want [puppet/pdk:latest]", result.Images)
+	}
+
+	calls := runner.Calls()
+	if len(calls) != 1 || calls[0].Name != "docker" || calls[0].Args[0] != "load" || calls[0].Args[1] != "--input" {
+		t.Fatalf("got calls %+v, want one docker load", calls)
+	}
+
+	result, err = newTestBundler(fakeDocker()).Import(context.Background(), archive, opts)
+	if err != nil {
+		t.Fatalf("second Import() error = %v", err)
+	}
+
+	if len(result.Installed) != 0 || len(result.Skipped) != 1 || result.Skipped[0] != "pdk/demo/0.1.0" {
+		t.Errorf("second Import() installed %v and skipped %v, want pdk/demo/0.1.0 skipped", result.Installed, result.Skipped)
+	}
+}
+
+func TestExportManifest(t *testing.T) {
+	root := extract(t, export(t))
+
+	data, err := os.ReadFile(filepath.Join(root, ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Version != ManifestVersion {
+		t.Errorf("Version = %d, want %d", manifest.Version, ManifestVersion)
+	}
+
+	want := Package{Kind: KindTemplate, Author: "pdk", ID: "demo", Version: "0.1.0", Path: "templates/pdk/demo/0.1.0"}
+	if len(manifest.Packages) != 1 {
+		t.Fatalf("got packages %+v, want one", manifest.Packages)
+	}
+
+	got := manifest.Packages[0]
+	if !strings.HasPrefix(got.Checksum, "sha256:") {
+		t.Errorf("Checksum = %q, want a sha256", got.Checksum)
+	}
+
+	got.Checksum = ""
+	if got != want {
+		t.Errorf("got package %+v, want %+v", got, want)
+	}
+
+	if len(manifest.Images) != 1 || manifest.Images[0].Path != "images/puppet_pdk_latest.tar" {
+		t.Errorf("got images %+v, want images/puppet_pdk_latest.tar", manifest.Images)
+	}
+}
+
+func TestImportChecksumMismatch(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(t *testing.T, root string)
+		want string
+	}{
+		{
+			name: "changed file",
+			edit: func(t *testing.T, root string) {
+				writeFile(t, filepath.Join(root, "templates", "pdk", "demo", "0.1.0", "content", "demo.txt.tmpl"), "tampered")
+			},
+			want: "checksum mismatch for pdk/demo/0.1.0",
+		},
+		{
+			name: "added file",
+			edit: func(t *testing.T, root string) {
+				writeFile(t, filepath.Join(root, "templates", "pdk", "demo", "0.1.0", "content", "extra.txt"), "extra")
+			},
+			want: "checksum mismatch for pdk/demo/0.1.0",
+		},
+		{
+			name: "changed image",
+			edit: func(t *testing.T, root string) {
+				writeFile(t, filepath.Join(root, "images", "puppet_pdk_latest.tar"), "tampered")
+			},
+			want: "checksum mismatch for image puppet/pdk:latest",
+		},
+	}
+
+	archive := export(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := repack(t, archive, tt.edit)
+
+			runner := fakeDocker()
+			templatePath := t.TempDir()
+
+			_, err := newTestBundler(runner).Import(context.Background(), tampered, ImportOptions{TemplatePath: templatePath, ToolPath: t.TempDir()})
+			if kind := pdk_errors.KindOf(err); kind != pdk_errors.InvalidPackage {
+				t.Fatalf("KindOf(%v) = %v, want %v", err, kind, pdk_errors.InvalidPackage)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+
+			if entries, _ := os.ReadDir(templatePath); len(entries) != 0 {
+				t.Errorf("got %d entries in the template path, want nothing installed", len(entries))
+			}
+			if calls := runner.Calls(); len(calls) != 0 {
+				t.Errorf("got docker calls %+v, want none", calls)
+			}
+		})
+	}
+}
+
+func TestImportPathOutsideBundle(t *testing.T) {
+	archive := repack(t, export(t), func(t *testing.T, root string) {
+		manifest := Manifest{
+			Version:  ManifestVersion,
+			Packages: []Package{{Kind: KindTemplate, Author: "pdk", ID: "demo", Version: "0.1.0", Path: "../escape"}},
+		}
+
+		data, err := yaml.Marshal(manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writeFile(t, filepath.Join(root, ManifestFile), string(data))
+	})
+
+	_, err := newTestBundler(fakeDocker()).Import(context.Background(), archive, ImportOptions{TemplatePath: t.TempDir(), ToolPath: t.TempDir()})
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.InvalidPackage {
+		t.Fatalf("KindOf(%v) = %v, want %v", err, kind, pdk_errors.InvalidPackage)
+	}
+	if !strings.Contains(err.Error(), "is outside of the bundle") {
+		t.Errorf("error = %q, want it to say the path is outside of the bundle", err)
+	}
+}
+
+func TestBundlePath(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "bundle")
+
+	tests := []struct {
+		rel  string
+		want string
+	}{
+		{rel: "templates/pdk/demo/0.1.0", want: filepath.Join(root, "templates", "pdk", "demo", "0.1.0")},
+		{rel: "images/../images/a.tar", want: filepath.Join(root, "images", "a.tar")},
+		{rel: "../escape"},
+		{rel: "templates/../../escape"},
+		{rel: "/etc/passwd"},
+		{rel: ".."},
+		{rel: "."},
+		{rel: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			got, err := bundlePath(root, tt.rel)
+			if tt.want == "" {
+				if kind := pdk_errors.KindOf(err); kind != pdk_errors.InvalidPackage {
+					t.Errorf("bundlePath(%q) = %q, %v, want an InvalidPackage error", tt.rel, got, err)
+				}
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Errorf("bundlePath(%q) = %q, %v, want %q", tt.rel, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+// Package credentials adds per-host authentication to HTTP requests made by
+// the pdk.
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const redacted = "********"
+
+// Credential holds the authentication details for a single host.
+type Credential struct {
+	// Host is the host name that the credential is used for. It may include
+	// a port.
+	Host string `json:"host" yaml:"host" mapstructure:"host"`
+
+	// Type is one of 'bearer', 'basic' or 'netrc'.
+	Type string `json:"type" yaml:"type" mapstructure:"type"`
+
+	// Token is sent as a bearer token. TokenEnv names an environment
+	// variable to read the token from instead.
+	Token    string `json:"token,omitempty" yaml:"token,omitempty" mapstructure:"token"`
+	TokenEnv string `json:"token_env,omitempty" yaml:"token_env,omitempty" mapstructure:"token_env"`
+
+	// Username and Password are sent using basic auth. PasswordEnv names an
+	// environment variable to read the password from instead.
+	Username    string `json:"username,omitempty" yaml:"username,omitempty" mapstructure:"username"`
+	Password    string `json:"password,omitempty" yaml:"password,omitempty" mapstructure:"password"`
+	PasswordEnv string `json:"password_env,omitempty" yaml:"password_env,omitempty" mapstructure:"password_env"`
+}
+
+// Redacted returns a copy of the credential with its secrets masked.
+func (c Credential) Redacted() Credential {
+	if c.Token != "" {
+		c.Token = redacted
+	}
+
+	if c.Password != "" {
+		c.Password = redacted
+	}
+
+	return c
+}
+
+// MarshalYAML ensures that secrets are never written when the credential is
+// printed.
+func (c Credential) MarshalYAML() (interface{}, error) {
+	type plain Credential
+	return plain(c.Redacted()), nil
+}
+
+// MarshalJSON ensures that secrets are never written when the credential is
+// logged.
+func (c Credential) MarshalJSON() ([]byte, error) {
+	type plain Credential
+	return json.Marshal(plain(c.Redacted()))
+}
+
+// Validate checks that the credential has everything that its type needs.
+func (c Credential) Validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("credentials need a host")
+	}
+
+	switch c.Type {
+	case "bearer":
+		if c.Token == "" && c.TokenEnv == "" {
+			return fmt.Errorf("bearer credentials need a token or token_env")
+		}
+	case "basic":
+		if c.Username == "" {
+			return fmt.Errorf("basic credentials need a username")
+		}
+		if c.Password == "" && c.PasswordEnv == "" {
+			return fmt.Errorf("basic credentials need a password or password_env")
+		}
+	case "netrc":
+	default:
+		return fmt.Errorf("invalid credential type %q. Valid values are: bearer, basic, netrc", c.Type)
+	}
+
+	return nil
+}
+
+func (c Credential) token() string {
+	if c.TokenEnv != "" {
+		return os.Getenv(c.TokenEnv)
+	}
+
+	return c.Token
+}
+
+func (c Credential) password() string {
+	if c.PasswordEnv != "" {
+		return os.Getenv(c.PasswordEnv)
+	}
+
+	return c.Password
+}
+
+// Store holds credentials for a set of hosts. Hosts that do not have a
+// credential fall back to the netrc file.
+type Store struct {
+	Credentials []Credential
+
+	// NetrcPath is the path of the netrc file. If empty $NETRC or ~/.netrc
+	// is used.
+	NetrcPath string
+}
+
+// Lookup returns the username and secret to use for host. For bearer
+// credentials the username is empty. ok is false if there are no
+// credentials for the host.
+func (s *Store) Lookup(host string) (kind, username, secret string, ok bool) {
+	return s.lookup(host, true)
+}
+
+// lookup is Lookup. The default entry of the netrc file is only used when
+// netrcDefault is true.
+func (s *Store) lookup(host string, netrcDefault bool) (kind, username, secret string, ok bool) {
+	if s == nil {
+		return "", "", "", false
+	}
+
+	c, found := s.find(host)
+	if !found {
+		c, found = s.find(stripPort(host))
+	}
+
+	if found && c.Type != "netrc" {
+		if c.Type == "bearer" {
+			return "bearer", "", c.token(), c.token() != ""
+		}
+
+		return "basic", c.Username, c.password(), true
+	}
+
+	login, password, found := lookupNetrc(s.netrcPath(), stripPort(host), netrcDefault)
+	if !found {
+		return "", "", "", false
+	}
+
+	return "basic", login, password, true
+}
+
+func (s *Store) find(host string) (Credential, bool) {
+	for _, c := range s.Credentials {
+		if strings.EqualFold(c.Host, host) {
+			return c, true
+		}
+	}
+
+	return Credential{}, false
+}
+
+func (s *Store) netrcPath() string {
+	if s.NetrcPath != "" {
+		return s.NetrcPath
+	}
+
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return netrcFile(home)
+}
+
+// Apply adds the credentials for the host of req to req. Credentials are
+// only sent over HTTPS, and requests that already carry an Authorization
+// header are left untouched. The default entry of the netrc file matches any
+// host, so it is not used for requests that a server redirected to.
+func (s *Store) Apply(req *http.Request) {
+	if req.URL.Scheme != "https" || req.Header.Get("Authorization") != "" {
+		return
+	}
+
+	redirected := req.Response != nil
+	kind, username, secret, ok := s.lookup(req.URL.Host, !redirected)
+	if !ok {
+		return
+	}
+
+	log.Debug().Str("host", req.URL.Host).Str("type", kind).Str("username", username).Bool("redirected", redirected).Msg("adding credentials to request")
+
+	if kind == "bearer" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+		return
+	}
+
+	req.SetBasicAuth(username, secret)
+}
+
+// Transport is an http.RoundTripper that adds credentials from a Store to
+// every HTTPS request. Credentials are looked up for each request, so a
+// request that is redirected to a different host only gets the credentials
+// configured for that host, or its machine entry in the netrc file.
+type Transport struct {
+	Base  http.RoundTripper
+	Store *Store
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if t.Store == nil {
+		return base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	t.Store.Apply(req)
+
+	return base.RoundTrip(req)
+}
+
+func stripPort(host string) string {
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.Contains(host[i:], "]") {
+		return host[:i]
+	}
+
+	return host
+}
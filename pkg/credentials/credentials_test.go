@@ -0,0 +1,222 @@
+package credentials
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// authServer starts an HTTPS server that responds with 401 unless the
+// request carries the given Authorization header.
+func authServer(t *testing.T, want string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != want {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func host(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u.Host
+}
+
+func get(t *testing.T, server *httptest.Server, store *Store) int {
+	t.Helper()
+
+	client := &http.Client{Transport: &Transport{Base: server.Client().Transport, Store: store}}
+	resp, err := client.Get(server.URL + "/package.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func writeNetrc(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), ".netrc")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestTransport(t *testing.T) {
+	t.Setenv("PDK_TEST_PASSWORD", "from-env")
+
+	tests := []struct {
+		name  string
+		want  string
+		store func(host string) *Store
+	}{
+		{
+			name: "bearer",
+			want: "Bearer s3cret",
+			store: func(host string) *Store {
+				return &Store{Credentials: []Credential{{Host: host, Type: "bearer", Token: "s3cret"}}}
+			},
+		},
+		{
+			name: "basic",
+			want: "Basic ZGVwbG95OmZyb20tZW52",
+			store: func(host string) *Store {
+				return &Store{Credentials: []Credential{{Host: host, Type: "basic", Username: "deploy", PasswordEnv: "PDK_TEST_PASSWORD"}}}
+			},
+		},
+		{
+			name: "host without port",
+			want: "Bearer s3cret",
+			store: func(host string) *Store {
+				return &Store{Credentials: []Credential{{Host: stripPort(host), Type: "bearer", Token: "s3cret"}}}
+			},
+		},
+		{
+			name: "netrc machine",
+			want: "Basic bmV0cmM6cGFzcw==",
+			store: func(host string) *Store {
+				return &Store{NetrcPath: writeNetrc(t, "machine other.example.com login x password y\nmachine "+stripPort(host)+" login netrc password pass\n")}
+			},
+		},
+		{
+			name: "netrc default",
+			want: "Basic ZGVmYXVsdDpwYXNz",
+			store: func(host string) *Store {
+				return &Store{NetrcPath: writeNetrc(t, "default login default password pass\n")}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := authServer(t, tt.want)
+
+			if status := get(t, server, tt.store(host(t, server))); status != http.StatusOK {
+				t.Errorf("got status %d, want %d", status, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestTransportUnauthorized(t *testing.T) {
+	server := authServer(t, "Bearer s3cret")
+
+	tests := []struct {
+		name  string
+		store *Store
+	}{
+		{name: "no credentials", store: &Store{NetrcPath: filepath.Join(t.TempDir(), "missing")}},
+		{name: "wrong token", store: &Store{Credentials: []Credential{{Host: host(t, server), Type: "bearer", Token: "wrong"}}}},
+		{name: "other host", store: &Store{Credentials: []Credential{{Host: "other.example.com", Type: "bearer", Token: "s3cret"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := get(t, server, tt.store); status != http.StatusUnauthorized {
+				t.Errorf("got status %d, want %d", status, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestTransportPlainHTTP(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	store := &Store{
+		Credentials: []Credential{{Host: host(t, server), Type: "bearer", Token: "s3cret"}},
+		NetrcPath:   writeNetrc(t, "default login default password pass\n"),
+	}
+
+	if status := get(t, server, store); status != http.StatusOK {
+		t.Fatalf("got status %d, want %d", status, http.StatusOK)
+	}
+
+	if got != "" {
+		t.Errorf("credentials were sent over http: %q", got)
+	}
+}
+
+func TestTransportRedirect(t *testing.T) {
+	var got []string
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+	}))
+	defer target.Close()
+
+	origin := authServer(t, "")
+	origin.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		http.Redirect(w, r, target.URL+"/package.tar.gz", http.StatusFound)
+	})
+
+	tests := []struct {
+		name  string
+		store *Store
+	}{
+		{
+			name:  "configured host",
+			store: &Store{Credentials: []Credential{{Host: host(t, origin), Type: "bearer", Token: "s3cret"}}, NetrcPath: filepath.Join(t.TempDir(), "missing")},
+		},
+		{
+			name:  "netrc default",
+			store: &Store{NetrcPath: writeNetrc(t, "default login default password pass\n")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+
+			if status := get(t, origin, tt.store); status != http.StatusOK {
+				t.Fatalf("got status %d, want %d", status, http.StatusOK)
+			}
+
+			if len(got) != 1 || got[0] != "" {
+				t.Errorf("the redirected request got credentials: %q", got)
+			}
+		})
+	}
+}
+
+func TestCredentialSecretsAreRedacted(t *testing.T) {
+	c := Credential{Host: "example.com", Type: "basic", Username: "deploy", Password: "s3cret"}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), "s3cret") {
+		t.Errorf("json contains the password: %s", b)
+	}
+}
@@ -0,0 +1,92 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+func netrcFile(home string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+
+	return filepath.Join(home, ".netrc")
+}
+
+// lookupNetrc returns the login and password for host from the netrc file at
+// path. The default entry is used if there is no entry for the host and
+// useDefault is true.
+func lookupNetrc(path, host string, useDefault bool) (login, password string, ok bool) {
+	if path == "" {
+		return "", "", false
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path is the users netrc file
+	if err != nil {
+		return "", "", false
+	}
+
+	var defLogin, defPassword string
+	var hasDefault bool
+
+	var machine string
+	var inDefault, inMacro bool
+	fields := strings.Fields(string(data))
+
+	for i := 0; i < len(fields); i++ {
+		if inMacro {
+			// Macro definitions run until an empty line which is lost by
+			// strings.Fields, so skip until the next keyword.
+			if fields[i] != "machine" && fields[i] != "default" {
+				continue
+			}
+			inMacro = false
+		}
+
+		next := func() string {
+			if i+1 < len(fields) {
+				i++
+				return fields[i]
+			}
+			return ""
+		}
+
+		switch fields[i] {
+		case "machine":
+			machine, inDefault = next(), false
+		case "default":
+			machine, inDefault, hasDefault = "", true, true
+		case "login":
+			value := next()
+			if machine == host {
+				login = value
+			} else if inDefault {
+				defLogin = value
+			}
+		case "password":
+			value := next()
+			if machine == host {
+				password, ok = value, true
+			} else if inDefault {
+				defPassword = value
+			}
+		case "account":
+			next()
+		case "macdef":
+			next()
+			inMacro = true
+		}
+	}
+
+	if ok {
+		return login, password, true
+	}
+
+	if useDefault && hasDefault && defPassword != "" {
+		return defLogin, defPassword, true
+	}
+
+	return "", "", false
+}
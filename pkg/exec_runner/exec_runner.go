@@ -2,56 +2,181 @@
 package exec_runner // nolint
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
+// ExecRunner runs external commands. Implementations must be safe for
+// concurrent use.
 type ExecRunner interface {
-	Command(name string, arg ...string) error
-	Output() ([]byte, error)
+	Run(ctx context.Context, name string, args []string, opts Options) (Result, error)
 }
 
-type execRunner struct {
-	cmd *exec.Cmd
+// Options controls how a command is run.
+type Options struct {
+	// Dir is the working directory of the command. If empty the current
+	// working directory is used.
+	Dir string
+
+	// Env holds extra environment variables in the form KEY=VALUE. They are
+	// added to the environment of the current process.
+	Env []string
+
+	// Stdin is read by the command if it is not nil.
+	Stdin io.Reader
+
+	// Stdout and Stderr receive the output of the command as it is written.
+	// The output is captured in the Result regardless.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Timeout limits how long the command may run. When it is exceeded the
+	// command and any processes that it started are killed. Zero means no
+	// timeout.
+	Timeout time.Duration
 }
 
-func (e *execRunner) Command(name string, args ...string) error {
-	var pathToExecutable string
-	var err error
+// Result holds the outcome of a command.
+type Result struct {
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
+	Duration time.Duration
+}
 
-	if runtime.GOOS == "windows" {
-		pathToExecutable, err = exec.LookPath("cmd.exe")
-	} else {
-		pathToExecutable, err = exec.LookPath(name)
+// ExitError is returned by Run when a command exits with a non-zero exit
+// code.
+type ExitError struct {
+	Name     string
+	ExitCode int
+	Stderr   []byte
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("%s exited with code %d", e.Name, e.ExitCode)
+	if stderr := strings.TrimSpace(string(e.Stderr)); stderr != "" {
+		msg = fmt.Sprintf("%s: %s", msg, stderr)
 	}
 
+	return msg
+}
+
+type execRunner struct{}
+
+// Run runs the named command with args and waits for it to finish. If ctx is
+// cancelled or the timeout is exceeded the whole process group is killed and
+// an error wrapping the context error is returned.
+func (e *execRunner) Run(ctx context.Context, name string, args []string, opts Options) (result Result, err error) {
+	path, argv, err := resolveCommand(name, args)
 	if err != nil {
-		return err
+		return result, err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
 	}
 
+	var stdout, stderr bytes.Buffer
 	cmd := &exec.Cmd{
-		Path: pathToExecutable,
-		Args: buildCommandArgs(name, args),
-		Env:  os.Environ(),
+		Path:   path,
+		Args:   argv,
+		Dir:    opts.Dir,
+		Env:    append(os.Environ(), opts.Env...),
+		Stdin:  opts.Stdin,
+		Stdout: teeWriter(&stdout, opts.Stdout),
+		Stderr: teeWriter(&stderr, opts.Stderr),
 	}
-	e.cmd = cmd
-	return nil
-}
+	setProcessGroup(cmd)
 
-func (e *execRunner) Output() ([]byte, error) {
-	return e.cmd.Output()
-}
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		result.Stdout = stdout.Bytes()
+		result.Stderr = stderr.Bytes()
+
+		event := log.Debug().
+			Str("path", path).
+			Strs("args", argv).
+			Str("dir", opts.Dir).
+			Int("exit_code", result.ExitCode).
+			Dur("duration", result.Duration)
+
+		if err != nil {
+			event.Err(err).Msg("command failed")
+			return
+		}
 
-func buildCommandArgs(commandName string, args []string) []string {
-	var cmd []string
+		event.Msg("command executed")
+	}()
 
+	if err := cmd.Start(); err != nil {
+		return result, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-done:
+		}
+	}()
+
+	err = cmd.Wait()
+	close(done)
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		result.ExitCode = -1
+		return result, fmt.Errorf("%s was stopped: %w", name, ctxErr)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+		return result, &ExitError{Name: name, ExitCode: result.ExitCode, Stderr: stderr.Bytes()}
+	}
+
+	return result, err
+}
+
+// resolveCommand returns the path of the executable and the argument list for
+// the named command. On Windows commands are run through cmd.exe.
+func resolveCommand(name string, args []string) (string, []string, error) {
 	if runtime.GOOS == "windows" {
-		cmd = append(cmd, "/c")
+		path, err := exec.LookPath("cmd.exe")
+		if err != nil {
+			return "", nil, err
+		}
+
+		return path, append([]string{path, "/c", name}, args...), nil
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return path, append([]string{name}, args...), nil
+}
+
+func teeWriter(buf *bytes.Buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return buf
 	}
-	cmd = append(cmd, commandName)
 
-	return append(cmd, args...)
+	return io.MultiWriter(buf, w)
 }
 
 func NewExecRunner() ExecRunner {
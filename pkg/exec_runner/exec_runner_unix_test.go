@@ -0,0 +1,127 @@
+//go:build !windows
+// +build !windows
+
+package exec_runner // nolint
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunCapturesOutput(t *testing.T) {
+	var stdout strings.Builder
+
+	result, err := NewExecRunner().Run(context.Background(), "sh", []string{"-c", "echo out; sleep 0.1"}, Options{Stdout: &stdout})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.ExitCode != 0 {
+		t.Errorf("ExitCode = %d, want 0", result.ExitCode)
+	}
+	if got := string(result.Stdout); got != "out\n" {
+		t.Errorf("Stdout = %q, want %q", got, "out\n")
+	}
+	if got := stdout.String(); got != "out\n" {
+		t.Errorf("streamed stdout = %q, want %q", got, "out\n")
+	}
+	if result.Duration < 100*time.Millisecond {
+		t.Errorf("Duration = %v, want at least 100ms", result.Duration)
+	}
+}
+
+func TestRunExitError(t *testing.T) {
+	result, err := NewExecRunner().Run(context.Background(), "sh", []string{"-c", "echo broken >&2; exit 3"}, Options{})
+
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Run() error = %v, want *ExitError", err)
+	}
+
+	if exitErr.Name != "sh" {
+		t.Errorf("Name = %q, want sh", exitErr.Name)
+	}
+	if exitErr.ExitCode != 3 || result.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, result.ExitCode = %d, want 3", exitErr.ExitCode, result.ExitCode)
+	}
+	if got := string(exitErr.Stderr); got != "broken\n" {
+		t.Errorf("Stderr = %q, want %q", got, "broken\n")
+	}
+	if got, want := err.Error(), "sh exited with code 3: broken"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("Duration = %v, want it to be set", result.Duration)
+	}
+}
+
+func TestRunTimeoutKillsProcessGroup(t *testing.T) {
+	// The shell starts a sleep in the background and waits for it. Only
+	// killing the whole process group stops the sleep, and until it stops
+	// it holds stdout open so Run cannot return.
+	script := "sleep 30 & echo $!; wait"
+
+	start := time.Now()
+	result, err := NewExecRunner().Run(context.Background(), "sh", []string{"-c", script}, Options{Timeout: 200 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("Run() took %v, want it to stop at the timeout", elapsed)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() error = %v, want context.DeadlineExceeded", err)
+	}
+	if result.ExitCode != -1 {
+		t.Errorf("ExitCode = %d, want -1", result.ExitCode)
+	}
+	if result.Duration < 200*time.Millisecond {
+		t.Errorf("Duration = %v, want at least the timeout", result.Duration)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(result.Stdout)))
+	if err != nil {
+		t.Fatalf("reading child pid from %q: %v", result.Stdout, err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("child sleep %d survived the timeout", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	_, err := NewExecRunner().Run(ctx, "sleep", []string{"30"}, Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want context.Canceled", err)
+	}
+}
+
+// alive reports whether pid is a running process. Zombies that have not been
+// reaped yet count as stopped.
+func alive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil {
+		return false
+	}
+
+	// Without /proc the signal check is all there is to go on.
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+
+	// The state follows the command name, which is in parentheses.
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
@@ -0,0 +1,54 @@
+package exec_runner // nolint
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeCall records a single call to FakeExecRunner.Run.
+type FakeCall struct {
+	Name string
+	Args []string
+	Opts Options
+}
+
+// FakeExecRunner is an ExecRunner for tests. It records every call and
+// returns the result of Handler. If Handler is nil an empty successful result
+// is returned. Output in the result is also written to the Stdout and Stderr
+// writers of the call so that streaming can be tested.
+type FakeExecRunner struct {
+	Handler func(ctx context.Context, call FakeCall) (Result, error)
+
+	mu    sync.Mutex
+	calls []FakeCall
+}
+
+func (f *FakeExecRunner) Run(ctx context.Context, name string, args []string, opts Options) (Result, error) {
+	call := FakeCall{Name: name, Args: args, Opts: opts}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	if f.Handler == nil {
+		return Result{}, ctx.Err()
+	}
+
+	result, err := f.Handler(ctx, call)
+	if opts.Stdout != nil && len(result.Stdout) > 0 {
+		_, _ = opts.Stdout.Write(result.Stdout)
+	}
+	if opts.Stderr != nil && len(result.Stderr) > 0 {
+		_, _ = opts.Stderr.Write(result.Stderr)
+	}
+
+	return result, err
+}
+
+// Calls returns the calls that have been made so far.
+func (f *FakeExecRunner) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]FakeCall(nil), f.calls...)
+}
@@ -0,0 +1,220 @@
+// Package fixture runs the golden file tests of a template. Each fixture is a
+// directory under tests with a values.yml file of parameter values and the
+// output that the template is expected to render with them in expected.
+package fixture
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/chelnak/pdk/pkg/parameters"
+	"github.com/chelnak/pdk/pkg/pct_config_processor"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/render"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/afero"
+)
+
+const (
+	// TestsDir is the directory of a template that holds its fixtures.
+	TestsDir = "tests"
+
+	// ValuesFile holds the parameter values of a fixture.
+	ValuesFile = "values.yml"
+
+	// ExpectedDir holds the expected output of a fixture.
+	ExpectedDir = "expected"
+)
+
+// Result is the outcome of running a single fixture.
+type Result struct {
+	Name string
+
+	// Missing are expected files that were not rendered.
+	Missing []string
+
+	// Unexpected are rendered files that are not expected.
+	Unexpected []string
+
+	// Different are files whose rendered content is not what was expected.
+	// Existing holds the expected content.
+	Different []render.Change
+}
+
+// Passed returns true if the rendered output matched the expected output.
+func (r Result) Passed() bool {
+	return len(r.Missing) == 0 && len(r.Unexpected) == 0 && len(r.Different) == 0
+}
+
+// Runner runs the fixtures of a template.
+type Runner interface {
+	// List returns the names of the fixtures of the template in root.
+	List(root string) ([]string, error)
+
+	// Run renders the template in root with the values of the named fixture
+	// and compares the output with the expected output. When update is true
+	// the expected output is replaced with the rendered output instead.
+	Run(root, name string, update bool) (Result, error)
+}
+
+type runner struct {
+	AFS      *afero.Afero
+	Renderer render.Renderer
+}
+
+func (r *runner) List(root string) ([]string, error) {
+	dir := filepath.Join(root, TestsDir)
+	entries, err := r.AFS.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not read %s", dir)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		if ok, _ := r.AFS.Exists(filepath.Join(dir, e.Name(), ValuesFile)); ok {
+			names = append(names, e.Name())
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (r *runner) Run(root, name string, update bool) (Result, error) {
+	result := Result{Name: name}
+	dir := filepath.Join(root, TestsDir, name)
+
+	processor := &pct_config_processor.PctConfigProcessor{AFS: r.AFS}
+	info, err := processor.ReadConfig(filepath.Join(root, "pct-config.yml"))
+	if err != nil {
+		return result, pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "could not read the config of %s", root)
+	}
+
+	provided, err := parameters.ReadValuesFile(filepath.Join(dir, ValuesFile))
+	if err != nil {
+		return result, pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "fixture %s", name)
+	}
+
+	values, err := parameters.Resolve(info.Parameters, provided, nil)
+	if err != nil {
+		return result, pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "fixture %s", name)
+	}
+
+	files, err := r.Renderer.Render(root, parameters.Data(info.Defaults, info.Parameters, values))
+	if err != nil {
+		return result, err
+	}
+
+	expectedDir := filepath.Join(dir, ExpectedDir)
+	if update {
+		return result, r.write(expectedDir, files)
+	}
+
+	expected, err := r.read(expectedDir)
+	if err != nil {
+		return result, err
+	}
+
+	for _, f := range files {
+		want, ok := expected[f.Path]
+		if !ok {
+			result.Unexpected = append(result.Unexpected, f.Path)
+			continue
+		}
+
+		delete(expected, f.Path)
+		if !bytes.Equal(want, f.Content) {
+			result.Different = append(result.Different, render.Change{File: f, Action: render.Conflict, Existing: want})
+		}
+	}
+
+	for path := range expected {
+		result.Missing = append(result.Missing, path)
+	}
+	sort.Strings(result.Missing)
+
+	return result, nil
+}
+
+// read returns the contents of the files in dir by their slash separated
+// path relative to dir.
+func (r *runner) read(dir string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	if ok, _ := r.AFS.DirExists(dir); !ok {
+		return files, nil
+	}
+
+	err := r.AFS.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := r.AFS.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not read %s", dir)
+	}
+
+	return files, nil
+}
+
+// write replaces the contents of dir with files.
+func (r *runner) write(dir string, files []render.File) error {
+	if err := r.AFS.RemoveAll(dir); err != nil {
+		return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not remove %s", dir)
+	}
+
+	for _, f := range files {
+		dest := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := r.AFS.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+			return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create %s", filepath.Dir(dest))
+		}
+
+		if err := r.AFS.WriteFile(dest, f.Content, f.Mode); err != nil {
+			return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not write %s", dest)
+		}
+	}
+
+	return nil
+}
+
+// Diff returns a unified diff from the expected to the rendered content of
+// a file that is different. It is empty for binary files.
+func Diff(change render.Change) (string, error) {
+	if change.Binary {
+		return "", nil
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(change.Existing)),
+		B:        difflib.SplitLines(string(change.Content)),
+		FromFile: change.Path + " (expected)",
+		ToFile:   change.Path + " (rendered)",
+		Context:  3,
+	})
+}
+
+// NewRunner returns a Runner that works on the local file system.
+func NewRunner() Runner {
+	return &runner{AFS: &afero.Afero{Fs: afero.NewOsFs()}, Renderer: render.NewRenderer()}
+}
@@ -0,0 +1,362 @@
+// Package git_fetcher fetches the contents of git repositories without
+// relying on a git binary being installed.
+package git_fetcher // nolint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/network"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/sideband"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/rs/zerolog/log"
+)
+
+// Fetcher fetches a git repository in to a directory.
+type Fetcher interface {
+	Fetch(ctx context.Context, opts FetchOptions) error
+}
+
+// FetchOptions controls what is fetched.
+type FetchOptions struct {
+	// URL is the location of the repository. HTTPS, SSH, git and file URLs
+	// are supported, as are scp-like addresses such as git@host:org/repo.git.
+	URL string
+
+	// Dir is the directory that the repository is fetched in to. It must not
+	// exist or be empty.
+	Dir string
+
+	// Ref is a branch, tag or commit to check out. The default branch is
+	// used if it is empty.
+	Ref string
+
+	// Depth limits the number of commits that are fetched. Zero fetches the
+	// full history. Depth is ignored when Ref is a commit.
+	Depth int
+
+	// Token is used to authenticate HTTPS requests when it is not empty. It
+	// is sent as the password of Username.
+	Token    string
+	Username string
+
+	// Progress receives the progress messages sent by the remote.
+	Progress io.Writer
+
+	// HTTPClient is used for HTTP and HTTPS URLs so that they share the
+	// proxy and TLS settings used for downloads. It is only used for this
+	// fetch. The default go-git client is used if it is nil.
+	HTTPClient *http.Client
+}
+
+var commitPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+type fetcher struct{}
+
+// Fetch fetches the repository described by opts and checks it out.
+// Branches are tried before tags. If neither matches and the ref looks like
+// a commit, the full history is fetched and the commit is checked out.
+func (f *fetcher) Fetch(ctx context.Context, opts FetchOptions) (err error) {
+	start := time.Now()
+
+	if network.Offline() && !isLocal(opts.URL) {
+		return network.ErrOffline
+	}
+
+	defer func() {
+		event := log.Debug().
+			Str("url", redact(opts.URL)).
+			Str("ref", opts.Ref).
+			Int("depth", opts.Depth).
+			Dur("duration", time.Since(start))
+
+		if err != nil {
+			event.Err(err).Msg("git fetch failed")
+			return
+		}
+
+		event.Msg("git fetch")
+	}()
+
+	endpoint, err := transport.NewEndpoint(opts.URL)
+	if err != nil {
+		return err
+	}
+
+	auth, err := authMethod(endpoint, opts)
+	if err != nil {
+		return err
+	}
+
+	protocol, err := transportFor(endpoint, opts.HTTPClient)
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(opts.Dir)
+	err = f.fetch(ctx, protocol, endpoint, auth, opts)
+	if err != nil && os.IsNotExist(statErr) {
+		_ = os.RemoveAll(opts.Dir)
+	}
+
+	return err
+}
+
+// transportFor returns the transport that fetches from endpoint. HTTP and
+// HTTPS use httpClient when it is set. The client is only used for this
+// fetch, so the go-git protocol registry, which is global, is not changed.
+func transportFor(endpoint *transport.Endpoint, httpClient *http.Client) (transport.Transport, error) {
+	if httpClient != nil && (endpoint.Protocol == "http" || endpoint.Protocol == "https") {
+		return githttp.NewClient(httpClient), nil
+	}
+
+	return client.NewClient(endpoint)
+}
+
+func (f *fetcher) fetch(ctx context.Context, protocol transport.Transport, endpoint *transport.Endpoint, auth transport.AuthMethod, opts FetchOptions) (err error) {
+	session, err := protocol.NewUploadPackSession(endpoint, auth)
+	if err != nil {
+		return err
+	}
+	defer closeWith(session, &err)
+
+	advertised, err := session.AdvertisedReferencesContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	refs, err := advertised.AllReferences()
+	if err != nil {
+		return err
+	}
+
+	t, err := targetFor(advertised, refs, opts)
+	if err != nil {
+		return err
+	}
+
+	repo, err := git.PlainInit(opts.Dir, false)
+	if err != nil {
+		return err
+	}
+
+	req := packp.NewUploadPackRequestFromCapabilities(advertised.Capabilities)
+	req.Wants = t.wants
+	if t.depth > 0 {
+		req.Depth = packp.DepthCommits(t.depth)
+		if err := req.Capabilities.Set(capability.Shallow); err != nil {
+			return err
+		}
+	}
+
+	if opts.Progress == nil && advertised.Capabilities.Supports(capability.NoProgress) {
+		if err := req.Capabilities.Set(capability.NoProgress); err != nil {
+			return err
+		}
+	}
+
+	resp, err := session.UploadPack(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer closeWith(resp, &err)
+
+	if len(resp.Shallows) > 0 {
+		if err := repo.Storer.SetShallow(resp.Shallows); err != nil {
+			return err
+		}
+	}
+
+	if err := packfile.UpdateObjectStorage(repo.Storer, demux(req.Capabilities, resp, opts.Progress)); err != nil {
+		return err
+	}
+
+	commit, err := t.checkout(repo)
+	if err != nil {
+		return err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	return worktree.Checkout(&git.CheckoutOptions{Hash: commit, Force: true})
+}
+
+// target is what is fetched for a ref.
+type target struct {
+	// wants are the objects that are fetched.
+	wants []plumbing.Hash
+
+	// depth limits the number of commits that are fetched.
+	depth int
+
+	// commit is set when the ref is a commit that is looked up in the
+	// fetched history.
+	commit string
+}
+
+// targetFor returns what to fetch for the ref in opts. A single object is
+// fetched for the default branch, a branch or a tag. Every branch and tag is
+// fetched with their full history when the ref is a commit.
+func targetFor(advertised *packp.AdvRefs, refs memory.ReferenceStorage, opts FetchOptions) (target, error) {
+	if advertised.IsEmpty() {
+		return target{}, transport.ErrEmptyRemoteRepository
+	}
+
+	if opts.Ref == "" {
+		if advertised.Head == nil {
+			return target{}, fmt.Errorf("the repository has no default branch")
+		}
+
+		return target{wants: []plumbing.Hash{*advertised.Head}, depth: opts.Depth}, nil
+	}
+
+	for _, name := range []plumbing.ReferenceName{plumbing.NewBranchReferenceName(opts.Ref), plumbing.NewTagReferenceName(opts.Ref)} {
+		if ref, ok := refs[name]; ok {
+			return target{wants: []plumbing.Hash{ref.Hash()}, depth: opts.Depth}, nil
+		}
+	}
+
+	if !commitPattern.MatchString(opts.Ref) {
+		return target{}, fmt.Errorf("could not find a branch, tag or commit named %q", opts.Ref)
+	}
+
+	t := target{commit: opts.Ref}
+	seen := map[plumbing.Hash]bool{}
+	for name, ref := range refs {
+		if ref.Type() != plumbing.HashReference || !(name.IsBranch() || name.IsTag()) || seen[ref.Hash()] {
+			continue
+		}
+
+		seen[ref.Hash()] = true
+		t.wants = append(t.wants, ref.Hash())
+	}
+
+	return t, nil
+}
+
+// checkout returns the commit to check out once t has been fetched.
+// Annotated tags are resolved to the commit that they point to.
+func (t target) checkout(repo *git.Repository) (plumbing.Hash, error) {
+	if t.commit != "" {
+		hash, err := repo.ResolveRevision(plumbing.Revision(t.commit))
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("could not find a branch, tag or commit named %q: %w", t.commit, err)
+		}
+
+		return *hash, nil
+	}
+
+	tag, err := repo.TagObject(t.wants[0])
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return t.wants[0], nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit, err := tag.Commit()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return commit.Hash, nil
+}
+
+// demux separates the packfile from the progress messages in r when the
+// sideband capability was requested.
+func demux(capabilities *capability.List, r io.Reader, progress sideband.Progress) io.Reader {
+	var t sideband.Type
+	switch {
+	case capabilities.Supports(capability.Sideband64k):
+		t = sideband.Sideband64k
+	case capabilities.Supports(capability.Sideband):
+		t = sideband.Sideband
+	default:
+		return r
+	}
+
+	d := sideband.NewDemuxer(t, r)
+	d.Progress = progress
+	return d
+}
+
+// closeWith closes c and sets err to the error if err is nil.
+func closeWith(c io.Closer, err *error) {
+	if closeErr := c.Close(); closeErr != nil && *err == nil {
+		*err = closeErr
+	}
+}
+
+// redact removes the password from rawURL if it has one.
+func redact(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+
+	return rawURL
+}
+
+// isLocal returns true if rawURL refers to a repository on the local file
+// system.
+func isLocal(rawURL string) bool {
+	endpoint, err := transport.NewEndpoint(rawURL)
+	return err == nil && endpoint.Protocol == "file"
+}
+
+// authMethod returns the auth method for endpoint. SSH URLs use the
+// keys held by the running ssh-agent. HTTPS URLs use opts.Token if it is set.
+func authMethod(endpoint *transport.Endpoint, opts FetchOptions) (transport.AuthMethod, error) {
+	switch endpoint.Protocol {
+	case "ssh":
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+
+		auth, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, fmt.Errorf("could not use ssh-agent for authentication: %w", err)
+		}
+
+		return auth, nil
+	case "http", "https":
+		if opts.Token == "" {
+			return nil, nil
+		}
+
+		// Git hosts accept any non-empty username when a token is used as
+		// the password.
+		username := opts.Username
+		if username == "" {
+			username = "pdk"
+		}
+
+		return &githttp.BasicAuth{Username: username, Password: opts.Token}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// NewFetcher returns a Fetcher implemented in pure Go.
+func NewFetcher() Fetcher {
+	return &fetcher{}
+}
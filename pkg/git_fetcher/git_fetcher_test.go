@@ -0,0 +1,232 @@
+package git_fetcher // nolint
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/network"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// remote is a bare repository in a temp dir with the history:
+//
+//	first   tag v1.0.0                   README says v1
+//	second  branch master, tag v2.0.0    README says v2, the tag is annotated
+//	third   branch feature               adds feature.txt
+type remote struct {
+	url   string
+	first plumbing.Hash
+}
+
+func newRemote(t *testing.T) remote {
+	t.Helper()
+
+	// Local repositories are served by git-upload-pack.
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	work := t.TempDir()
+	repo, err := git.PlainInit(work, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := commit(t, worktree, "README.md", "v1")
+	if _, err := repo.CreateTag("v1.0.0", first, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	second := commit(t, worktree, "README.md", "v2")
+	if _, err := repo.CreateTag("v2.0.0", second, &git.CreateTagOptions{Tagger: signature(), Message: "v2.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, worktree, "feature.txt", "feature")
+
+	if err := worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.Master}); err != nil {
+		t.Fatal(err)
+	}
+
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	if _, err := git.PlainInit(bare, true); err != nil {
+		t.Fatal(err)
+	}
+
+	origin, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{bare}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = origin.Push(&git.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return remote{url: "file://" + filepath.ToSlash(bare), first: first}
+}
+
+func commit(t *testing.T, worktree *git.Worktree, name, content string) plumbing.Hash {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(worktree.Filesystem.Root(), name), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := worktree.Add(name); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := worktree.Commit("update "+name, &git.CommitOptions{Author: signature()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+func signature() *object.Signature {
+	return &object.Signature{Name: "pdk", Email: "pdk@example.com", When: time.Unix(1600000000, 0)}
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestFetch(t *testing.T) {
+	r := newRemote(t)
+
+	tests := []struct {
+		name    string
+		ref     string
+		readme  string
+		feature bool
+	}{
+		{name: "default branch", ref: "", readme: "v2"},
+		{name: "branch", ref: "feature", readme: "v2", feature: true},
+		{name: "tag", ref: "v1.0.0", readme: "v1"},
+		{name: "annotated tag", ref: "v2.0.0", readme: "v2"},
+		{name: "short commit", ref: r.first.String()[:7], readme: "v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "clone")
+
+			err := NewFetcher().Fetch(context.Background(), FetchOptions{URL: r.url, Dir: dir, Ref: tt.ref, Depth: 1})
+			if err != nil {
+				t.Fatalf("Fetch() returned an error: %v", err)
+			}
+
+			if readme := readFile(t, dir, "README.md"); readme != tt.readme {
+				t.Errorf("got README.md %q, want %q", readme, tt.readme)
+			}
+
+			_, err = os.Stat(filepath.Join(dir, "feature.txt"))
+			if exists := err == nil; exists != tt.feature {
+				t.Errorf("feature.txt exists: %t, want %t", exists, tt.feature)
+			}
+		})
+	}
+}
+
+func TestFetchMissingRef(t *testing.T) {
+	r := newRemote(t)
+	dir := filepath.Join(t.TempDir(), "clone")
+
+	err := NewFetcher().Fetch(context.Background(), FetchOptions{URL: r.url, Dir: dir, Ref: "missing", Depth: 1})
+	if err == nil {
+		t.Fatal("Fetch() returned no error")
+	}
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed after the failed fetch", dir)
+	}
+}
+
+func TestFetchOffline(t *testing.T) {
+	network.SetOffline(true)
+	defer network.SetOffline(false)
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	err := NewFetcher().Fetch(context.Background(), FetchOptions{URL: "https://git.example.com/org/repo.git", Dir: dir})
+	if !errors.Is(err, network.ErrOffline) {
+		t.Errorf("got error %v, want %v", err, network.ErrOffline)
+	}
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("%s was created in offline mode", dir)
+	}
+}
+
+func TestFetchOfflineLocal(t *testing.T) {
+	r := newRemote(t)
+
+	network.SetOffline(true)
+	defer network.SetOffline(false)
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	if err := NewFetcher().Fetch(context.Background(), FetchOptions{URL: r.url, Dir: dir, Depth: 1}); err != nil {
+		t.Fatalf("Fetch() returned an error for a local repository in offline mode: %v", err)
+	}
+
+	if readme := readFile(t, dir, "README.md"); readme != "v2" {
+		t.Errorf("got README.md %q, want %q", readme, "v2")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestFetchUsesHTTPClient(t *testing.T) {
+	var requested []string
+	httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.String())
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})}
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	err := NewFetcher().Fetch(context.Background(), FetchOptions{URL: "https://git.example.com/org/repo.git", Dir: dir, HTTPClient: httpClient})
+	if err == nil {
+		t.Fatal("Fetch() returned no error")
+	}
+
+	if len(requested) == 0 || !strings.HasPrefix(requested[0], "https://git.example.com/org/repo.git/info/refs") {
+		t.Errorf("the HTTP client was not used, got requests %q", requested)
+	}
+
+	if client.Protocols["https"] != githttp.DefaultClient {
+		t.Error("the go-git protocol registry was changed")
+	}
+}
@@ -0,0 +1,117 @@
+// Package hooks runs the commands that templates declare in the hooks
+// section of pct-config.yml before and after content is created.
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/exec_runner"
+	"github.com/chelnak/pdk/pkg/parameters"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/rs/zerolog/log"
+)
+
+// EnvPrefix is prepended to the upper cased name of each parameter to give
+// the environment variable that holds its value.
+const EnvPrefix = "PDK_PARAM_"
+
+var envNamePattern = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// Hook is a single command.
+type Hook struct {
+	// Name describes the hook. It is shown on the spinner.
+	Name    string   `mapstructure:"name"`
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+}
+
+// Label returns the name of the hook, or the command line if it has none.
+func (h Hook) Label() string {
+	if h.Name != "" {
+		return h.Name
+	}
+
+	return h.CommandLine()
+}
+
+// CommandLine returns the command and its arguments as a single string.
+func (h Hook) CommandLine() string {
+	return strings.TrimSpace(h.Command + " " + strings.Join(h.Args, " "))
+}
+
+// Hooks holds the hooks of a template.
+type Hooks struct {
+	// Pre runs before any content is written.
+	Pre []Hook `mapstructure:"pre"`
+
+	// Post runs after the content has been written.
+	Post []Hook `mapstructure:"post"`
+}
+
+// Len returns the total number of hooks.
+func (h Hooks) Len() int {
+	return len(h.Pre) + len(h.Post)
+}
+
+// Options controls how a hook is run.
+type Options struct {
+	// Dir is the working directory of the hook.
+	Dir string
+
+	// Env holds extra environment variables in the form KEY=VALUE.
+	Env []string
+
+	// Timeout limits how long the hook may run. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// Runner runs hooks.
+type Runner interface {
+	Run(ctx context.Context, hook Hook, opts Options) error
+}
+
+type runner struct {
+	Exec exec_runner.ExecRunner
+}
+
+// Run runs hook and returns an error if it fails or does not finish within
+// the timeout.
+func (r *runner) Run(ctx context.Context, hook Hook, opts Options) error {
+	log.Debug().Str("hook", hook.Label()).Str("dir", opts.Dir).Msg("running hook")
+
+	_, err := r.Exec.Run(ctx, hook.Command, hook.Args, exec_runner.Options{
+		Dir:     opts.Dir,
+		Env:     opts.Env,
+		Timeout: opts.Timeout,
+	})
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.Hook, err, "hook %q failed", hook.Label())
+	}
+
+	return nil
+}
+
+// Env returns the environment variables that pass values to hooks. Each
+// value is named after its key, upper cased and prefixed with EnvPrefix, so
+// that the parameter name is available as PDK_PARAM_NAME. Lists are comma
+// separated.
+func Env(values map[string]interface{}) []string {
+	env := make([]string, 0, len(values))
+	for name, value := range values {
+		key := EnvPrefix + envNamePattern.ReplaceAllString(strings.ToUpper(name), "_")
+		env = append(env, fmt.Sprintf("%s=%s", key, parameters.Format(value)))
+	}
+
+	sort.Strings(env)
+	return env
+}
+
+// NewRunner returns a Runner that runs hooks with exec_runner.
+func NewRunner() Runner {
+	return &runner{Exec: exec_runner.NewExecRunner()}
+}
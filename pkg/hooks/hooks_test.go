@@ -0,0 +1,102 @@
+package hooks
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/exec_runner"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+)
+
+func TestRunPassesOptions(t *testing.T) {
+	fake := &exec_runner.FakeExecRunner{}
+	r := &runner{Exec: fake}
+
+	hook := Hook{Name: "install gems", Command: "bundle", Args: []string{"install", "--quiet"}}
+	opts := Options{
+		Dir:     "/work/module",
+		Env:     []string{"PDK_PARAM_NAME=example"},
+		Timeout: 30 * time.Second,
+	}
+
+	if err := r.Run(context.Background(), hook, opts); err != nil {
+		t.Fatalf("Run() returned an error: %v", err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("got %d calls, want 1", len(calls))
+	}
+
+	call := calls[0]
+	if call.Name != "bundle" {
+		t.Errorf("got command %q, want %q", call.Name, "bundle")
+	}
+	if !reflect.DeepEqual(call.Args, hook.Args) {
+		t.Errorf("got args %q, want %q", call.Args, hook.Args)
+	}
+	if call.Opts.Dir != opts.Dir {
+		t.Errorf("got dir %q, want %q", call.Opts.Dir, opts.Dir)
+	}
+	if !reflect.DeepEqual(call.Opts.Env, opts.Env) {
+		t.Errorf("got env %q, want %q", call.Opts.Env, opts.Env)
+	}
+	if call.Opts.Timeout != opts.Timeout {
+		t.Errorf("got timeout %s, want %s", call.Opts.Timeout, opts.Timeout)
+	}
+}
+
+func TestRunWrapsFailures(t *testing.T) {
+	fake := &exec_runner.FakeExecRunner{
+		Handler: func(ctx context.Context, call exec_runner.FakeCall) (exec_runner.Result, error) {
+			return exec_runner.Result{ExitCode: 3}, &exec_runner.ExitError{Name: call.Name, ExitCode: 3}
+		},
+	}
+	r := &runner{Exec: fake}
+
+	err := r.Run(context.Background(), Hook{Command: "false"}, Options{})
+	if err == nil {
+		t.Fatal("Run() returned no error")
+	}
+
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.Hook {
+		t.Errorf("got kind %s, want %s", kind.Code, pdk_errors.Hook.Code)
+	}
+	if !strings.Contains(err.Error(), `hook "false" failed`) {
+		t.Errorf("error %q does not name the hook", err)
+	}
+}
+
+func TestRunReportsTimeouts(t *testing.T) {
+	fake := &exec_runner.FakeExecRunner{
+		Handler: func(ctx context.Context, call exec_runner.FakeCall) (exec_runner.Result, error) {
+			return exec_runner.Result{ExitCode: -1}, context.DeadlineExceeded
+		},
+	}
+	r := &runner{Exec: fake}
+
+	err := r.Run(context.Background(), Hook{Command: "sleep", Args: []string{"60"}}, Options{Timeout: time.Second})
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.Timeout {
+		t.Errorf("got kind %s, want %s", kind.Code, pdk_errors.Timeout.Code)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	env := Env(map[string]interface{}{
+		"name":      "example",
+		"os-family": []interface{}{"RedHat", "Debian"},
+		"tests":     true,
+	})
+
+	want := []string{
+		"PDK_PARAM_NAME=example",
+		"PDK_PARAM_OS_FAMILY=RedHat,Debian",
+		"PDK_PARAM_TESTS=true",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("got %q, want %q", env, want)
+	}
+}
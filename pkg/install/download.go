@@ -0,0 +1,287 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/network"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultDownloadName = "package.tar.gz"
+	retryBaseDelay      = 500 * time.Millisecond
+)
+
+// ProgressFunc is called as a download progresses. total is -1 when the size
+// of the download is unknown.
+type ProgressFunc func(downloaded, total int64)
+
+// download holds the state of a download across retries.
+type download struct {
+	url     *url.URL
+	dir     string
+	path    string
+	written int64
+	// validator is the strong ETag or Last-Modified date of the response
+	// that was written to path. It is sent as If-Range on resume so that a
+	// package that changed between attempts is downloaded again in full.
+	validator string
+}
+
+// downloadTemplate downloads the package at targetURL in to downloadDir and
+// returns its path. Failed attempts are retried with exponential backoff and
+// resumed with a conditional Range request when the server supports it.
+func (p *installer) downloadTemplate(ctx context.Context, targetURL *url.URL, downloadDir string) (string, error) {
+	log.Debug().Str("url", targetURL.Redacted()).Str("dir", downloadDir).Msg("downloading package")
+
+	d := &download{url: targetURL, dir: downloadDir}
+
+	var err error
+	for attempt := 0; attempt <= p.Retries; attempt++ {
+		if attempt > 0 {
+			delay := retryBaseDelay << (attempt - 1)
+			log.Debug().Err(err).Int("attempt", attempt).Dur("delay", delay).Int64("resume_from", d.written).Msg("retrying download")
+
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		err = p.downloadAttempt(ctx, d)
+		if err == nil {
+			return d.path, nil
+		}
+
+		if !isRetryable(ctx, err) {
+			return "", err
+		}
+	}
+
+	return "", err
+}
+
+// retryableError marks an error that is worth retrying.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var r *retryableError
+	return errors.As(err, &r)
+}
+
+func (p *installer) downloadAttempt(ctx context.Context, d *download) (err error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url.String(), nil)
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.NotFound, err, "could not create request for %s", d.url.Redacted())
+	}
+
+	if d.written > 0 && d.validator != "" {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.written))
+		request.Header.Set("If-Range", d.validator)
+	}
+
+	response, err := p.HTTPClient.Do(request)
+	if errors.Is(err, network.ErrOffline) {
+		return network.ErrOffline
+	}
+
+	if err != nil && network.IsTLSError(err) {
+		return pdk_errors.Wrap(pdk_errors.TLS, err, "could not download %s: %s", d.url.Redacted(), network.DescribeTLSError(err))
+	}
+
+	if err != nil {
+		return &retryableError{pdk_errors.Wrap(pdk_errors.Network, err, "request to %s failed", d.url.Redacted())}
+	}
+
+	defer func() {
+		if closeErr := response.Body.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if err := checkStatus(response, d.url); err != nil {
+		return err
+	}
+
+	resume := response.StatusCode == http.StatusPartialContent && request.Header.Get("Range") != ""
+	if resume && !resumesAt(response, d.written) {
+		// The server sent a different part of the package than was asked
+		// for, so the partial download cannot be trusted. Start again.
+		log.Debug().Str("content_range", response.Header.Get("Content-Range")).Int64("resume_from", d.written).Msg("restarting download")
+		d.written = 0
+		d.validator = ""
+		return p.downloadAttempt(ctx, d)
+	}
+
+	if !resume {
+		d.written = 0
+		d.validator = validator(response)
+	}
+
+	if d.path == "" {
+		d.path = filepath.Join(d.dir, downloadName(response))
+	}
+
+	total := response.ContentLength
+	if total >= 0 {
+		total += d.written
+	}
+
+	if p.MaxDownloadSize > 0 && total > p.MaxDownloadSize {
+		return pdk_errors.New(pdk_errors.InvalidPackage, "package at %s is %d bytes which exceeds the maximum download size of %d bytes", d.url.Redacted(), total, p.MaxDownloadSize)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := p.AFS.OpenFile(d.path, flags, 0600)
+	if err != nil {
+		return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create %s", d.path)
+	}
+
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	var body io.Reader = response.Body
+	if p.MaxDownloadSize > 0 {
+		// Read one byte more than allowed so that oversized bodies without a
+		// Content-Length can be detected.
+		body = io.LimitReader(body, p.MaxDownloadSize-d.written+1)
+	}
+
+	w := &progressWriter{w: file, written: d.written, total: total, progress: p.Progress}
+	_, copyErr := io.Copy(w, body)
+	d.written = w.written
+
+	if p.MaxDownloadSize > 0 && d.written > p.MaxDownloadSize {
+		return pdk_errors.New(pdk_errors.InvalidPackage, "package at %s exceeds the maximum download size of %d bytes", d.url.Redacted(), p.MaxDownloadSize)
+	}
+
+	if copyErr != nil {
+		return &retryableError{pdk_errors.Wrap(pdk_errors.Network, copyErr, "download from %s was interrupted", d.url.Redacted())}
+	}
+
+	if total >= 0 && d.written < total {
+		return &retryableError{pdk_errors.New(pdk_errors.Network, "download from %s ended after %d of %d bytes", d.url.Redacted(), d.written, total)}
+	}
+
+	return nil
+}
+
+// checkStatus returns an error for responses that do not contain the
+// package. Server errors and rate limiting are retryable.
+func checkStatus(response *http.Response, u *url.URL) error {
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+
+	err := pdk_errors.New(pdk_errors.Network, "Received response code %d when trying to download from %s", response.StatusCode, u.Redacted())
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		err.Kind = pdk_errors.NotFound
+	case response.StatusCode == http.StatusUnauthorized, response.StatusCode == http.StatusForbidden:
+		err.Kind = pdk_errors.Unauthorized
+	case response.StatusCode == http.StatusTooManyRequests, response.StatusCode >= 500:
+		return &retryableError{err}
+	}
+
+	return err
+}
+
+// resumesAt reports whether the Content-Range of a partial response starts at
+// offset.
+func resumesAt(response *http.Response, offset int64) bool {
+	var start, end int64
+	var size string
+
+	_, err := fmt.Sscanf(response.Header.Get("Content-Range"), "bytes %d-%d/%s", &start, &end, &size)
+	return err == nil && start == offset && end >= start
+}
+
+// validator returns the value to send as If-Range when resuming the download
+// of response. Weak ETags cannot be used for range requests.
+func validator(response *http.Response) string {
+	if etag := response.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return response.Header.Get("Last-Modified")
+}
+
+// downloadName returns the name to save a download as. The name is taken from
+// the Content-Disposition header if there is one, otherwise from the final
+// URL after any redirects. Query strings are ignored.
+func downloadName(response *http.Response) string {
+	if cd := response.Header.Get("Content-Disposition"); cd != "" {
+		if _, params, err := mime.ParseMediaType(cd); err == nil {
+			if name := sanitizeName(params["filename"]); name != "" {
+				return name
+			}
+		}
+	}
+
+	if response.Request != nil && response.Request.URL != nil {
+		if name := sanitizeName(path.Base(response.Request.URL.Path)); name != "" {
+			return name
+		}
+	}
+
+	return defaultDownloadName
+}
+
+// sanitizeName strips any directory components from name so that a server
+// cannot write outside of the download directory.
+func sanitizeName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+
+	return name
+}
+
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.written += int64(n)
+
+	if pw.progress != nil {
+		pw.progress(pw.written, pw.total)
+	}
+
+	return n, err
+}
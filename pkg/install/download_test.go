@@ -0,0 +1,315 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/chelnak/pdk/pkg/credentials"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+)
+
+const packageContent = "package content"
+
+// packageServer starts an HTTPS server that serves a package, or responds
+// with 401 unless the request carries the given Authorization header.
+func packageServer(t *testing.T, want string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != want {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(packageContent))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newTestInstaller(server *httptest.Server, store *credentials.Store) *installer {
+	return NewInstaller(Options{Credentials: store, Transport: server.Client().Transport}).(*installer)
+}
+
+func fetchPackage(t *testing.T, p *installer, rawURL string) (string, error) {
+	t.Helper()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p.downloadTemplate(context.Background(), u, t.TempDir())
+}
+
+func serverHost(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u.Host
+}
+
+func TestDownloadWithCredentials(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), ".netrc")
+
+	tests := []struct {
+		name  string
+		want  string
+		store func(host string) *credentials.Store
+	}{
+		{
+			name: "bearer",
+			want: "Bearer s3cret",
+			store: func(host string) *credentials.Store {
+				return &credentials.Store{Credentials: []credentials.Credential{{Host: host, Type: "bearer", Token: "s3cret"}}}
+			},
+		},
+		{
+			name: "basic",
+			want: "Basic ZGVwbG95OnMzY3JldA==",
+			store: func(host string) *credentials.Store {
+				return &credentials.Store{Credentials: []credentials.Credential{{Host: host, Type: "basic", Username: "deploy", Password: "s3cret"}}}
+			},
+		},
+		{
+			name: "netrc",
+			want: "Basic ZGVwbG95OnMzY3JldA==",
+			store: func(host string) *credentials.Store {
+				name, _, err := net.SplitHostPort(host)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				content := "machine " + name + " login deploy password s3cret\n"
+				if err := os.WriteFile(netrc, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+
+				return &credentials.Store{
+					Credentials: []credentials.Credential{{Host: host, Type: "netrc"}},
+					NetrcPath:   netrc,
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := packageServer(t, tt.want)
+			p := newTestInstaller(server, tt.store(serverHost(t, server)))
+
+			path, err := fetchPackage(t, p, server.URL+"/example-0.1.0.tar.gz")
+			if err != nil {
+				t.Fatalf("downloadTemplate() returned an error: %v", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(content) != packageContent {
+				t.Errorf("got %q, want %q", content, packageContent)
+			}
+
+			if filepath.Base(path) != "example-0.1.0.tar.gz" {
+				t.Errorf("got file name %q, want %q", filepath.Base(path), "example-0.1.0.tar.gz")
+			}
+		})
+	}
+}
+
+func TestDownloadUnauthorized(t *testing.T) {
+	server := packageServer(t, "Bearer s3cret")
+	store := &credentials.Store{
+		Credentials: []credentials.Credential{{Host: serverHost(t, server), Type: "bearer", Token: "wrong"}},
+	}
+
+	_, err := fetchPackage(t, newTestInstaller(server, store), server.URL+"/example-0.1.0.tar.gz")
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.Unauthorized {
+		t.Errorf("got kind %s, want %s: %v", kind.Code, pdk_errors.Unauthorized.Code, err)
+	}
+}
+
+func TestDownloadRedirectDropsCredentials(t *testing.T) {
+	var got []string
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(packageContent))
+	}))
+	defer target.Close()
+
+	origin := packageServer(t, "Bearer s3cret")
+	origin.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		http.Redirect(w, r, target.URL+"/example-0.1.0.tar.gz", http.StatusFound)
+	})
+
+	store := &credentials.Store{
+		Credentials: []credentials.Credential{{Host: serverHost(t, origin), Type: "bearer", Token: "s3cret"}},
+		NetrcPath:   filepath.Join(t.TempDir(), "missing"),
+	}
+
+	if _, err := fetchPackage(t, newTestInstaller(origin, store), origin.URL+"/download"); err != nil {
+		t.Fatalf("downloadTemplate() returned an error: %v", err)
+	}
+
+	if len(got) != 1 || got[0] != "" {
+		t.Errorf("the redirected request got credentials: %q", got)
+	}
+}
+
+// interrupted writes the first half of packageContent with the full length
+// and then drops the connection.
+func interrupted(w http.ResponseWriter, header http.Header) {
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+
+	w.Header().Set("Content-Length", fmt.Sprint(len(packageContent)))
+	_, _ = w.Write([]byte(packageContent[:len(packageContent)/2]))
+	w.(http.Flusher).Flush()
+}
+
+func TestDownloadResume(t *testing.T) {
+	half := len(packageContent) / 2
+	rest := func(w http.ResponseWriter) {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", half, len(packageContent)-1, len(packageContent)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte(packageContent[half:]))
+	}
+
+	tests := []struct {
+		name    string
+		handler func(attempt int, w http.ResponseWriter, r *http.Request)
+		want    string
+		ranges  []string
+	}{
+		{
+			name: "etag",
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				if attempt == 0 {
+					interrupted(w, http.Header{"Etag": {`"v1"`}})
+					return
+				}
+
+				if r.Header.Get("If-Range") != `"v1"` {
+					t.Errorf("got If-Range %q, want %q", r.Header.Get("If-Range"), `"v1"`)
+				}
+				rest(w)
+			},
+			want:   packageContent,
+			ranges: []string{"", fmt.Sprintf("bytes=%d-", half)},
+		},
+		{
+			name: "last modified",
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				modified := "Mon, 02 Jan 2006 15:04:05 GMT"
+				if attempt == 0 {
+					interrupted(w, http.Header{"Etag": {`W/"weak"`}, "Last-Modified": {modified}})
+					return
+				}
+
+				if r.Header.Get("If-Range") != modified {
+					t.Errorf("got If-Range %q, want %q", r.Header.Get("If-Range"), modified)
+				}
+				rest(w)
+			},
+			want:   packageContent,
+			ranges: []string{"", fmt.Sprintf("bytes=%d-", half)},
+		},
+		{
+			name: "changed package",
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				if attempt == 0 {
+					interrupted(w, http.Header{"Etag": {`"v1"`}})
+					return
+				}
+
+				_, _ = w.Write([]byte("changed package"))
+			},
+			want:   "changed package",
+			ranges: []string{"", fmt.Sprintf("bytes=%d-", half)},
+		},
+		{
+			name: "wrong content range",
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				switch attempt {
+				case 0:
+					interrupted(w, http.Header{"Etag": {`"v1"`}})
+				case 1:
+					w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(packageContent)-1, len(packageContent)))
+					w.WriteHeader(http.StatusPartialContent)
+					_, _ = w.Write([]byte(packageContent))
+				default:
+					_, _ = w.Write([]byte(packageContent))
+				}
+			},
+			want:   packageContent,
+			ranges: []string{"", fmt.Sprintf("bytes=%d-", half), ""},
+		},
+		{
+			name: "no validator",
+			handler: func(attempt int, w http.ResponseWriter, r *http.Request) {
+				if attempt == 0 {
+					interrupted(w, nil)
+					return
+				}
+
+				_, _ = w.Write([]byte(packageContent))
+			},
+			want:   packageContent,
+			ranges: []string{"", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []string
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := len(ranges)
+				ranges = append(ranges, r.Header.Get("Range"))
+				tt.handler(attempt, w, r)
+			}))
+			defer server.Close()
+
+			store := &credentials.Store{NetrcPath: filepath.Join(t.TempDir(), "missing")}
+			p := NewInstaller(Options{Credentials: store, Transport: server.Client().Transport, Retries: 1}).(*installer)
+
+			path, err := fetchPackage(t, p, server.URL+"/example-0.1.0.tar.gz")
+			if err != nil {
+				t.Fatalf("downloadTemplate() returned an error: %v", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(content) != tt.want {
+				t.Errorf("got %q, want %q", content, tt.want)
+			}
+
+			if !reflect.DeepEqual(ranges, tt.ranges) {
+				t.Errorf("got Range headers %q, want %q", ranges, tt.ranges)
+			}
+		})
+	}
+}
@@ -3,32 +3,73 @@
 package install
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/chelnak/pdk/internal/logging"
+	"github.com/chelnak/pdk/pkg/credentials"
 	"github.com/chelnak/pdk/pkg/exec_runner"
+	"github.com/chelnak/pdk/pkg/git_fetcher"
+	"github.com/chelnak/pdk/pkg/network"
 	"github.com/chelnak/pdk/pkg/pct_config_processor"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
 	"github.com/puppetlabs/pct/pkg/config_processor"
 
 	"github.com/puppetlabs/pct/pkg/gzip"
-	"github.com/puppetlabs/pct/pkg/httpclient"
 	"github.com/puppetlabs/pct/pkg/tar"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/afero"
 )
 
+// configFileName is the name of the config file at the root of every
+// package.
+const configFileName = "pct-config.yml"
+
 type ConfigParams struct {
 	ID      string `mapstructure:"id"`
 	Author  string `mapstructure:"author"`
 	Version string `mapstructure:"version"`
 }
 
+// HTTPClient is the subset of *http.Client used to download packages.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Options configures how an Installer accesses the network.
+type Options struct {
+	// Credentials are used to authenticate downloads and git fetches.
+	Credentials *credentials.Store
+
+	// Transport carries downloads and git fetches over HTTP. It is where
+	// proxy and TLS settings are applied. network.DefaultTransport is used
+	// if it is nil.
+	Transport http.RoundTripper
+
+	// Retries is the number of times a failed download is retried.
+	Retries int
+
+	// MaxDownloadSize is the largest package in bytes that will be
+	// downloaded. Zero means no limit.
+	MaxDownloadSize int64
+
+	// Progress is called as packages are downloaded.
+	Progress ProgressFunc
+
+	// ToolPath is where packages with the type tool are installed. When it
+	// is empty every package is installed in the target directory.
+	ToolPath string
+}
+
 type Installer interface {
-	Install(templatePkg, targetDir string, force bool) (string, error)
-	InstallClone(GitURI, targetDir string, force bool) (string, error)
+	Install(ctx context.Context, templatePkg, targetDir string, force bool) (string, error)
+	InstallClone(ctx context.Context, GitURI, targetDir string, force bool) (string, error)
+	InstallFromConfig(configFile, targetDir string, force bool) (string, error)
 }
 
 type installer struct {
@@ -36,172 +77,205 @@ type installer struct {
 	Gunzip          gzip.GunzipI
 	AFS             *afero.Afero
 	IOFS            *afero.IOFS
-	HTTPClient      httpclient.HTTPClientI
+	HTTPClient      HTTPClient
 	Exec            exec_runner.ExecRunner
+	Git             git_fetcher.Fetcher
+	Credentials     *credentials.Store
+	Transport       http.RoundTripper
+	Retries         int
+	MaxDownloadSize int64
+	Progress        ProgressFunc
+	ToolPath        string
 	ConfigProcessor config_processor.ConfigProcessorI
 	ConfigFile      string
 }
 
-func (p *installer) Install(templatePkg, targetDir string, force bool) (namespacedPath string, err error) {
+func (p *installer) Install(ctx context.Context, templatePkg, targetDir string, force bool) (namespacedPath string, err error) {
+	log.Debug().Str("source", redact(templatePkg)).Str("target", targetDir).Bool("force", force).Msg("installing package")
+
 	// Check if the template package path is a url
 	if strings.HasPrefix(templatePkg, "http") {
+		if network.Offline() {
+			return "", pdk_errors.New(pdk_errors.Offline, "cannot download %s in offline mode", redact(templatePkg))
+		}
+
+		// Create a temporary Directory to download the tar.gz to. It must
+		// outlive the download as the package is extracted from it below.
+		downloadDir, err := p.AFS.TempDir("", "")
+		if err != nil {
+			return "", pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create tempdir to download package")
+		}
+
+		defer func() {
+			if removeErr := p.AFS.RemoveAll(downloadDir); removeErr != nil {
+				log.Debug().Err(removeErr).Str("dir", downloadDir).Msg("failed to remove download dir")
+			}
+		}()
+
 		// Download the tar.gz file and change templatePkg to its download path
-		err := p.processDownload(&templatePkg)
+		templatePkg, err = p.processDownload(ctx, templatePkg, downloadDir)
 		if err != nil {
 			return "", err
 		}
 	}
 
 	if _, err := p.AFS.Stat(templatePkg); os.IsNotExist(err) {
-		return "", fmt.Errorf("no package at %v", templatePkg)
+		return "", pdk_errors.New(pdk_errors.NotFound, "no package at %v", templatePkg)
 	}
 
 	// create a temporary Directory to extract the tar.gz to
 	tempDir, err := p.AFS.TempDir("", "")
 	defer func() {
 		if removeErr := p.AFS.RemoveAll(tempDir); removeErr != nil {
-			err = fmt.Errorf("error cleaning up temp dir: %v", removeErr)
+			err = pdk_errors.Wrap(pdk_errors.FileSystem, removeErr, "error cleaning up temp dir")
 		}
 	}()
 
 	if err != nil {
-		return "", fmt.Errorf("could not create tempdir to gunzip package: %v", err)
+		return "", pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create tempdir to gunzip package")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
 
 	// gunzip the tar.gz to created tempdir
+	log.Debug().Str("package", templatePkg).Str("dir", tempDir).Msg("extracting package")
 	tarfile, err := p.Gunzip.Gunzip(templatePkg, tempDir)
 	if err != nil {
-		return "", fmt.Errorf("could not extract TAR from GZIP (%v): %v", templatePkg, err)
+		return "", pdk_errors.Wrap(pdk_errors.InvalidPackage, err, "could not extract TAR from GZIP (%v)", templatePkg)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
 
 	// untar the above archive to the temp dir
 	untarPath, err := p.Tar.Untar(tarfile, tempDir)
 	if err != nil {
-		return "", fmt.Errorf("could not UNTAR package (%v): %v", templatePkg, err)
+		return "", pdk_errors.Wrap(pdk_errors.InvalidPackage, err, "could not UNTAR package (%v)", templatePkg)
 	}
 
 	// Process the configuration file and set up namespacedPath and relocate config and content to it
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	namespacedPath, err = p.InstallFromConfig(filepath.Join(untarPath, p.ConfigFile), targetDir, force)
 	if err != nil {
-		return "", fmt.Errorf("invalid config: %v", err.Error())
+		return "", err
 	}
 
 	return namespacedPath, nil
 }
 
-func (p *installer) processDownload(templatePkg *string) (err error) {
-	u, err := url.ParseRequestURI(*templatePkg)
+func (p *installer) processDownload(ctx context.Context, templatePkg, downloadDir string) (string, error) {
+	u, err := url.ParseRequestURI(templatePkg)
 	if err != nil {
-		return fmt.Errorf("could not parse package url %s: %v", *templatePkg, err)
+		return "", pdk_errors.Wrap(pdk_errors.NotFound, err, "could not parse package url %s", redact(templatePkg))
 	}
-	// Create a temporary Directory to download the tar.gz to
-	tempDownloadDir, err := p.AFS.TempDir("", "")
-	defer func() {
-		if removeErr := p.AFS.Remove(tempDownloadDir); removeErr != nil {
-			err = fmt.Errorf("error cleaning up temp dir: %v", removeErr)
-		}
-	}()
-	if err != nil {
-		return fmt.Errorf("could not create tempdir to download package: %v", err)
-	}
-	// Download template and assign location to templatePkg
-	*templatePkg, err = p.downloadTemplate(u, tempDownloadDir)
+
+	// Download template and return its location
+	downloadPath, err := p.downloadTemplate(ctx, u, downloadDir)
 	if err != nil {
-		return fmt.Errorf("could not effectively download package: %v", err)
+		return "", pdk_errors.Wrap(pdk_errors.KindOf(err), err, "could not effectively download package")
 	}
-	return nil
+
+	return downloadPath, nil
 }
 
-func (p *installer) InstallClone(GitURI string, targetDir string, force bool) (namespacedPath string, err error) {
+func (p *installer) InstallClone(ctx context.Context, GitURI string, targetDir string, force bool) (namespacedPath string, err error) {
+	log.Debug().Str("source", redact(GitURI)).Str("target", targetDir).Bool("force", force).Msg("installing package from git")
+
 	// Create temp dir
 	tempDir, err := p.AFS.TempDir("", "")
 	defer func() {
 		if cleanErr := os.RemoveAll(tempDir); cleanErr != nil {
-			err = fmt.Errorf("error cleaning up temp dir: %v", cleanErr)
+			err = pdk_errors.Wrap(pdk_errors.FileSystem, cleanErr, "error cleaning up temp dir")
 		}
 	}()
 
-	// Validate git URI
-	_, err = url.ParseRequestURI(GitURI)
-	if err != nil {
-		return "", fmt.Errorf("could not parse package uri %s: %v", GitURI, err)
+	// Split an optional ref from the end of the URI, e.g. https://host/repo.git#v1.0.0
+	gitURL, ref := GitURI, ""
+	if i := strings.LastIndex(GitURI, "#"); i != -1 {
+		gitURL, ref = GitURI[:i], GitURI[i+1:]
+	}
+
+	// Validate git URI. scp-like addresses such as git@host:org/repo.git are not URLs.
+	if strings.Contains(gitURL, "://") {
+		_, err = url.ParseRequestURI(gitURL)
+		if err != nil {
+			return "", pdk_errors.Wrap(pdk_errors.NotFound, err, "could not parse package uri %s", redact(GitURI))
+		}
 	}
 
 	// Clone git repository to temp folder
-	folderPath, err := p.cloneTemplate(GitURI, tempDir)
+	folderPath, err := p.cloneTemplate(ctx, gitURL, ref, tempDir)
 	if err != nil {
-		return "", fmt.Errorf("could not clone git repository: %v", err)
+		if errors.Is(err, network.ErrOffline) {
+			return "", pdk_errors.New(pdk_errors.Offline, "cannot clone %s in offline mode", redact(gitURL))
+		}
+
+		if network.IsTLSError(err) {
+			return "", pdk_errors.Wrap(pdk_errors.TLS, err, "could not clone git repository: %s", network.DescribeTLSError(err))
+		}
+
+		return "", pdk_errors.Wrap(pdk_errors.Git, err, "could not clone git repository")
 	}
 
 	// Remove .git folder from cloned repository
 	err = p.AFS.RemoveAll(filepath.Join(folderPath, ".git"))
 	if err != nil {
-		return "", fmt.Errorf("failed to remove '.git' directory")
+		return "", pdk_errors.Wrap(pdk_errors.FileSystem, err, "failed to remove '.git' directory")
 	}
 
-	return p.InstallFromConfig(filepath.Join(folderPath, p.ConfigFile), targetDir, force)
-}
-
-func (p *installer) cloneTemplate(GitURI string, tempDir string) (string, error) {
-	clonePath := filepath.Join(tempDir, "temp")
-
-	err := p.Exec.Command("git", "clone", GitURI, clonePath)
-	if err != nil {
+	if err := ctx.Err(); err != nil {
 		return "", err
 	}
 
-	_, err = p.Exec.Output()
-	if err != nil {
-		return "", err
-	}
-	return clonePath, nil
+	return p.InstallFromConfig(filepath.Join(folderPath, p.ConfigFile), targetDir, force)
 }
 
-func (p *installer) downloadTemplate(targetURL *url.URL, downloadDir string) (downloadPath string, err error) {
-	// Get the file contents from URL
-	response, err := p.HTTPClient.Get(targetURL.String())
-	if err != nil {
-		return "", err
-	}
-
-	defer func() {
-		if closeErr := response.Body.Close(); closeErr != nil {
-			err = closeErr
-		}
-	}()
+func (p *installer) cloneTemplate(ctx context.Context, GitURI, ref, tempDir string) (string, error) {
+	clonePath := filepath.Join(tempDir, "temp")
 
-	if response.StatusCode != 200 {
-		message := fmt.Sprintf("Received response code %d when trying to download from %s", response.StatusCode, targetURL.String())
-		return "", errors.New(message)
+	opts := git_fetcher.FetchOptions{
+		URL:   GitURI,
+		Dir:   clonePath,
+		Ref:   ref,
+		Depth: 1,
 	}
 
-	// Create the empty file
-	fileName := filepath.Base(targetURL.Path)
-	downloadPath = filepath.Join(downloadDir, fileName)
-	file, err := p.AFS.Create(downloadPath)
-	if err != nil {
-		return "", err
+	if p.Transport != nil {
+		opts.HTTPClient = &http.Client{Transport: logging.NewTransport(p.Transport)}
 	}
 
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			err = closeErr
+	if u, err := url.Parse(GitURI); err == nil && u.Scheme == "https" {
+		if _, username, secret, ok := p.Credentials.Lookup(u.Host); ok {
+			opts.Username, opts.Token = username, secret
 		}
-	}()
+	}
 
-	// Write file contents
-	err = p.AFS.WriteReader(downloadPath, response.Body)
+	err := p.Git.Fetch(ctx, opts)
 	if err != nil {
 		return "", err
 	}
 
-	return downloadPath, nil
+	return clonePath, nil
 }
 
 func (p *installer) InstallFromConfig(configFile, targetDir string, force bool) (string, error) {
 	info, err := p.ConfigProcessor.GetConfigMetadata(configFile)
 	if err != nil {
-		return "", err
+		return "", pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "invalid config")
+	}
+
+	if p.ToolPath != "" {
+		processor := &pct_config_processor.PctConfigProcessor{AFS: p.AFS}
+		if config, err := processor.ReadConfig(configFile); err == nil && config.Template.Kind() == pct_config_processor.Tool {
+			log.Debug().Str("path", p.ToolPath).Msg("installing tool in the tool path")
+			targetDir = p.ToolPath
+		}
 	}
 
 	// Create namespaced directory and move contents of temp folder to it
@@ -209,12 +283,14 @@ func (p *installer) InstallFromConfig(configFile, targetDir string, force bool)
 
 	err = p.AFS.MkdirAll(installedPkgPath, 0750)
 	if err != nil {
-		return "", err
+		return "", pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create install directory")
 	}
 
 	installedPkgPath = filepath.Join(installedPkgPath, info.Version)
 	untarredPkgDir := filepath.Dir(configFile)
 
+	log.Debug().Str("author", info.Author).Str("id", info.Id).Str("version", info.Version).Str("path", installedPkgPath).Msg("moving package in to namespace")
+
 	// finally move to the full path
 	errMsgPrefix := "Unable to install in namespace:"
 	err = p.AFS.Rename(untarredPkgDir, installedPkgPath)
@@ -222,17 +298,17 @@ func (p *installer) InstallFromConfig(configFile, targetDir string, force bool)
 		// if a template already exists
 		if !force {
 			// error unless forced
-			return "", fmt.Errorf("%s Package already installed", errMsgPrefix)
+			return "", pdk_errors.New(pdk_errors.AlreadyInstalled, "%s Package already installed", errMsgPrefix)
 		} else {
 			// remove the exiting template
 			err = p.AFS.RemoveAll(installedPkgPath)
 			if err != nil {
-				return "", fmt.Errorf("%s Unable to overwrite existing package: %v", errMsgPrefix, err)
+				return "", pdk_errors.Wrap(pdk_errors.FileSystem, err, "%s Unable to overwrite existing package", errMsgPrefix)
 			}
 			// perform the move again
 			err = p.AFS.Rename(untarredPkgDir, installedPkgPath)
 			if err != nil {
-				return "", fmt.Errorf("%s Unable to force install: %v", errMsgPrefix, err)
+				return "", pdk_errors.Wrap(pdk_errors.FileSystem, err, "%s Unable to force install", errMsgPrefix)
 			}
 		}
 	}
@@ -240,17 +316,39 @@ func (p *installer) InstallFromConfig(configFile, targetDir string, force bool)
 	return installedPkgPath, err
 }
 
-func NewInstaller() Installer {
+// redact removes the password from source if it is a URL that has one.
+func redact(source string) string {
+	if u, err := url.Parse(source); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+
+	return source
+}
+
+func NewInstaller(opts Options) Installer {
 	fs := afero.NewOsFs()
 	execRunner := exec_runner.NewExecRunner()
 
+	transport := opts.Transport
+	if transport == nil {
+		transport = network.DefaultTransport()
+	}
+
 	return &installer{
 		Tar:             &tar.Tar{AFS: &afero.Afero{Fs: fs}},
 		Gunzip:          &gzip.Gunzip{AFS: &afero.Afero{Fs: fs}},
 		AFS:             &afero.Afero{Fs: fs},
 		IOFS:            &afero.IOFS{Fs: fs},
+		HTTPClient:      &http.Client{Transport: logging.NewTransport(&credentials.Transport{Base: transport, Store: opts.Credentials})},
 		Exec:            execRunner,
+		Git:             git_fetcher.NewFetcher(),
+		Credentials:     opts.Credentials,
+		Transport:       transport,
+		Retries:         opts.Retries,
+		MaxDownloadSize: opts.MaxDownloadSize,
+		Progress:        opts.Progress,
+		ToolPath:        opts.ToolPath,
 		ConfigProcessor: &pct_config_processor.PctConfigProcessor{AFS: &afero.Afero{Fs: fs}},
-		ConfigFile:      "pct-config.yml",
+		ConfigFile:      configFileName,
 	}
 }
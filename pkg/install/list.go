@@ -0,0 +1,122 @@
+package install
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// InstalledPackage describes a package that has been installed in to an
+// install root.
+type InstalledPackage struct {
+	Author  string
+	ID      string
+	Version string
+	Path    string
+}
+
+// Name returns the namespaced name of the package in the form author/id.
+func (i InstalledPackage) Name() string {
+	return fmt.Sprintf("%s/%s", i.Author, i.ID)
+}
+
+// List returns the packages installed in root. Packages are expected to use
+// the author/id/version layout created by InstallFromConfig. The result is
+// sorted by name and version.
+func List(root string) ([]InstalledPackage, error) {
+	return list(&afero.Afero{Fs: afero.NewOsFs()}, root)
+}
+
+func list(afs *afero.Afero, root string) ([]InstalledPackage, error) {
+	matches, err := afero.Glob(afs, filepath.Join(root, "*", "*", "*", configFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []InstalledPackage
+	for _, match := range matches {
+		versionDir := filepath.Dir(match)
+		idDir := filepath.Dir(versionDir)
+
+		packages = append(packages, InstalledPackage{
+			Author:  filepath.Base(filepath.Dir(idDir)),
+			ID:      filepath.Base(idDir),
+			Version: filepath.Base(versionDir),
+			Path:    versionDir,
+		})
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Name() != packages[j].Name() {
+			return packages[i].Name() < packages[j].Name()
+		}
+		return compareVersions(packages[i].Version, packages[j].Version) < 0
+	})
+
+	return packages, nil
+}
+
+// Find returns the package in packages with the given name. The name
+// is either author/id, which selects the highest installed version, or
+// author/id/version.
+func Find(packages []InstalledPackage, name string) (InstalledPackage, bool) {
+	var found InstalledPackage
+	ok := false
+
+	for _, p := range packages {
+		if name == p.Name()+"/"+p.Version {
+			return p, true
+		}
+
+		if name == p.Name() && (!ok || compareVersions(p.Version, found.Version) > 0) {
+			found, ok = p, true
+		}
+	}
+
+	return found, ok
+}
+
+// compareVersions compares two semantic versions by their major, minor and
+// patch numbers. Versions that can not be parsed are compared as strings.
+func compareVersions(a, b string) int {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	if !okA || !okB {
+		return strings.Compare(a, b)
+	}
+
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+
+	core := strings.SplitN(strings.SplitN(v, "-", 2)[0], "+", 2)[0]
+	fields := strings.Split(core, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+
+	return parts, true
+}
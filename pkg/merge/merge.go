@@ -0,0 +1,167 @@
+// Package merge implements a line based three-way merge. It is used to bring
+// the changes between two versions of a template in to a project that was
+// generated from the older one without losing local changes.
+package merge
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Labels name the sides of a conflict in the conflict markers.
+type Labels struct {
+	Ours   string
+	Base   string
+	Theirs string
+}
+
+// Result is the outcome of a merge.
+type Result struct {
+	Content []byte
+
+	// Conflicts is the number of conflicting regions. Each is surrounded by
+	// conflict markers in Content.
+	Conflicts int
+}
+
+// region is a run of lines that is the same in base, ours and theirs.
+type region struct {
+	baseStart, baseEnd     int
+	oursStart, oursEnd     int
+	theirsStart, theirsEnd int
+}
+
+// Merge applies the changes from base to theirs on top of ours. Regions that
+// were changed on only one side take that side. Regions that were changed in
+// the same way on both sides are taken once. Regions that were changed
+// differently are written with both versions between conflict markers in the
+// style of git, with base shown between ||||||| and ======= so that it is
+// clear what each side changed.
+func Merge(base, ours, theirs []byte, labels Labels) Result {
+	b, o, t := splitLines(base), splitLines(ours), splitLines(theirs)
+
+	var out bytes.Buffer
+	var conflicts int
+	var bi, oi, ti int
+
+	for _, r := range syncRegions(b, o, t) {
+		baseChunk, oursChunk, theirsChunk := b[bi:r.baseStart], o[oi:r.oursStart], t[ti:r.theirsStart]
+
+		switch {
+		case equal(oursChunk, baseChunk):
+			write(&out, theirsChunk)
+		case equal(theirsChunk, baseChunk), equal(oursChunk, theirsChunk):
+			write(&out, oursChunk)
+		default:
+			conflicts++
+			marker(&out, "<<<<<<< ", labels.Ours)
+			write(&out, oursChunk)
+			marker(&out, "||||||| ", labels.Base)
+			write(&out, baseChunk)
+			marker(&out, "=======", "")
+			write(&out, theirsChunk)
+			marker(&out, ">>>>>>> ", labels.Theirs)
+		}
+
+		write(&out, b[r.baseStart:r.baseEnd])
+		bi, oi, ti = r.baseEnd, r.oursEnd, r.theirsEnd
+	}
+
+	return Result{Content: out.Bytes(), Conflicts: conflicts}
+}
+
+// syncRegions returns the regions where base, ours and theirs all match,
+// ending with an empty region at the end of all three.
+func syncRegions(base, ours, theirs []string) []region {
+	oursMatches := difflib.NewMatcherWithJunk(base, ours, false, nil).GetMatchingBlocks()
+	theirsMatches := difflib.NewMatcherWithJunk(base, theirs, false, nil).GetMatchingBlocks()
+
+	var regions []region
+	for i, j := 0, 0; i < len(oursMatches) && j < len(theirsMatches); {
+		om, tm := oursMatches[i], theirsMatches[j]
+
+		start := max(om.A, tm.A)
+		end := min(om.A+om.Size, tm.A+tm.Size)
+		if start < end {
+			oursStart := om.B + start - om.A
+			theirsStart := tm.B + start - tm.A
+			regions = append(regions, region{
+				baseStart: start, baseEnd: end,
+				oursStart: oursStart, oursEnd: oursStart + end - start,
+				theirsStart: theirsStart, theirsEnd: theirsStart + end - start,
+			})
+		}
+
+		if om.A+om.Size < tm.A+tm.Size {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return append(regions, region{
+		baseStart: len(base), baseEnd: len(base),
+		oursStart: len(ours), oursEnd: len(ours),
+		theirsStart: len(theirs), theirsEnd: len(theirs),
+	})
+}
+
+// splitLines splits content in to lines that keep their line endings.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func write(out *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// marker writes a conflict marker. The previous line is ended first if it
+// was the last line of a file without a trailing newline.
+func marker(out *bytes.Buffer, prefix, label string) {
+	if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		out.WriteString("\n")
+	}
+
+	out.WriteString(strings.TrimSpace(prefix+label) + "\n")
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
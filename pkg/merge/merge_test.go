@@ -0,0 +1,151 @@
+package merge
+
+import "testing"
+
+func TestMerge(t *testing.T) {
+	labels := Labels{Ours: "project", Base: "pdk/demo/0.1.0", Theirs: "pdk/demo/0.2.0"}
+
+	tests := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		{
+			name:   "unchanged",
+			base:   "a\nb\n",
+			ours:   "a\nb\n",
+			theirs: "a\nb\n",
+			want:   "a\nb\n",
+		},
+		{
+			name:   "changed by theirs",
+			base:   "a\nb\n",
+			ours:   "a\nb\n",
+			theirs: "a\nB\n",
+			want:   "a\nB\n",
+		},
+		{
+			name:   "changed by ours",
+			base:   "a\nb\n",
+			ours:   "a\nB\n",
+			theirs: "a\nb\n",
+			want:   "a\nB\n",
+		},
+		{
+			name:   "edits that do not overlap",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "A\nb\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			want:   "A\nb\nc\nd\nE\n",
+		},
+		{
+			name:   "lines added and removed on different sides",
+			base:   "a\nb\nc\nd\n",
+			ours:   "header\na\nb\nc\nd\n",
+			theirs: "a\nb\nd\nfooter\n",
+			want:   "header\na\nb\nd\nfooter\n",
+		},
+		{
+			name:   "the same edit on both sides",
+			base:   "a\nb\nc\n",
+			ours:   "a\nX\nc\n",
+			theirs: "a\nX\nc\n",
+			want:   "a\nX\nc\n",
+		},
+		{
+			name:   "overlapping edits",
+			base:   "a\nb\nc\n",
+			ours:   "a\nours\nc\n",
+			theirs: "a\ntheirs\nc\n",
+			want: "a\n" +
+				"<<<<<<< project\n" +
+				"ours\n" +
+				"||||||| pdk/demo/0.1.0\n" +
+				"b\n" +
+				"=======\n" +
+				"theirs\n" +
+				">>>>>>> pdk/demo/0.2.0\n" +
+				"c\n",
+			conflicts: 1,
+		},
+		{
+			name:   "two conflicts",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "A1\nb\nc\nd\nE1\n",
+			theirs: "A2\nb\nc\nd\nE2\n",
+			want: "<<<<<<< project\nA1\n||||||| pdk/demo/0.1.0\na\n=======\nA2\n>>>>>>> pdk/demo/0.2.0\n" +
+				"b\nc\nd\n" +
+				"<<<<<<< project\nE1\n||||||| pdk/demo/0.1.0\ne\n=======\nE2\n>>>>>>> pdk/demo/0.2.0\n",
+			conflicts: 2,
+		},
+		{
+			name:   "added on both sides with the same content",
+			ours:   "new\n",
+			theirs: "new\n",
+			want:   "new\n",
+		},
+		{
+			name:   "added on both sides with different content",
+			ours:   "ours\n",
+			theirs: "theirs\n",
+			want: "<<<<<<< project\n" +
+				"ours\n" +
+				"||||||| pdk/demo/0.1.0\n" +
+				"=======\n" +
+				"theirs\n" +
+				">>>>>>> pdk/demo/0.2.0\n",
+			conflicts: 1,
+		},
+		{
+			name:   "deleted by theirs and edited by ours",
+			base:   "a\nb\n",
+			ours:   "a\nB\n",
+			theirs: "",
+			want: "<<<<<<< project\n" +
+				"a\nB\n" +
+				"||||||| pdk/demo/0.1.0\n" +
+				"a\nb\n" +
+				"=======\n" +
+				">>>>>>> pdk/demo/0.2.0\n",
+			conflicts: 1,
+		},
+		{
+			name:   "no trailing newline",
+			base:   "a\nb",
+			ours:   "a\nb",
+			theirs: "a\nc",
+			want:   "a\nc",
+		},
+		{
+			name:   "conflict without a trailing newline",
+			base:   "a\nb",
+			ours:   "a\nours",
+			theirs: "a\ntheirs",
+			want: "a\n" +
+				"<<<<<<< project\n" +
+				"ours\n" +
+				"||||||| pdk/demo/0.1.0\n" +
+				"b\n" +
+				"=======\n" +
+				"theirs\n" +
+				">>>>>>> pdk/demo/0.2.0\n",
+			conflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Merge([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs), labels)
+			if string(got.Content) != tt.want {
+				t.Errorf("got content\n%s\nwant\n%s", got.Content, tt.want)
+			}
+
+			if got.Conflicts != tt.conflicts {
+				t.Errorf("got %d conflicts, want %d", got.Conflicts, tt.conflicts)
+			}
+		})
+	}
+}
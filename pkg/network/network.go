@@ -0,0 +1,243 @@
+// Package network builds the HTTP transport used by the pdk. The transport
+// honours the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables and
+// can trust a custom CA bundle and present a client certificate, which is
+// needed behind proxies that intercept TLS.
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Options controls how connections are secured.
+type Options struct {
+	// CAFile is a PEM bundle of certificates that are trusted in addition to
+	// the system roots.
+	CAFile string
+
+	// ClientCert and ClientKey are PEM files holding a certificate and key
+	// that are presented to servers that ask for one. Both must be set.
+	ClientCert string
+	ClientKey  string
+}
+
+// TLSConfig returns the TLS configuration described by opts.
+func TLSConfig(opts Options) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CAFile != "" {
+		pool, _, err := loadCAFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, pdk_errors.New(pdk_errors.InvalidConfig, "client_cert and client_key must be set together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, pdk_errors.Wrap(pdk_errors.TLS, err, "could not load client certificate %s", opts.ClientCert)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// CACertificates returns the number of certificates in the CA bundle at path.
+func CACertificates(path string) (int, error) {
+	_, count, err := loadCAFile(path)
+	return count, err
+}
+
+// loadCAFile returns the system roots with the certificates in path added
+// to them.
+func loadCAFile(path string) (*x509.CertPool, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, pdk_errors.Wrap(pdk_errors.TLS, err, "could not read ca_file")
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		log.Debug().Err(err).Msg("system cert pool unavailable, using ca_file only")
+		pool = x509.NewCertPool()
+	}
+
+	count := 0
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, 0, pdk_errors.Wrap(pdk_errors.TLS, err, "invalid certificate in ca_file %s", path)
+		}
+
+		pool.AddCert(cert)
+		count++
+	}
+
+	if count == 0 {
+		return nil, 0, pdk_errors.New(pdk_errors.TLS, "ca_file %s does not contain any PEM certificates", path)
+	}
+
+	log.Debug().Str("file", path).Int("certificates", count).Msg("loaded ca_file")
+	return pool, count, nil
+}
+
+// NewTransport returns an http.Transport that uses the proxy from the
+// environment and the TLS configuration described by opts.
+func NewTransport(opts Options) (*http.Transport, error) {
+	cfg, err := TLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := DefaultTransport()
+	transport.TLSClientConfig = cfg
+
+	return transport, nil
+}
+
+// DefaultTransport returns an http.Transport that uses the proxy from the
+// environment and the system roots. Like every transport returned by this
+// package, it refuses to connect in offline mode.
+func DefaultTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.DialContext = dialContext(&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	})
+
+	return transport
+}
+
+// ProxyFor returns the proxy that transport uses for rawURL. It returns nil
+// if the connection is made directly.
+func ProxyFor(transport *http.Transport, rawURL string) (*url.URL, error) {
+	if transport.Proxy == nil {
+		return nil, nil
+	}
+
+	req, err := http.NewRequest(http.MethodHead, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return transport.Proxy(req)
+}
+
+// CheckHost makes a request to host over HTTPS and returns an error if a
+// secure connection could not be established. Any HTTP response, whatever
+// its status, means that the connection is trusted.
+func CheckHost(ctx context.Context, transport *http.Transport, host string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "https://"+host+"/", nil)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	response, err := client.Do(req)
+	if err != nil {
+		if IsTLSError(err) {
+			return pdk_errors.New(pdk_errors.TLS, "%s", DescribeTLSError(err))
+		}
+
+		return pdk_errors.Wrap(pdk_errors.Network, err, "could not connect to %s", host)
+	}
+
+	return response.Body.Close()
+}
+
+// IsTLSError returns true if err was caused by a failed TLS handshake or a
+// certificate that could not be verified.
+func IsTLSError(err error) bool {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		systemRoots      x509.SystemRootsError
+		recordHeader     tls.RecordHeaderError
+	)
+
+	switch {
+	case errors.As(err, &unknownAuthority),
+		errors.As(err, &hostname),
+		errors.As(err, &invalid),
+		errors.As(err, &systemRoots),
+		errors.As(err, &recordHeader):
+		return true
+	}
+
+	return err != nil && (strings.Contains(err.Error(), "tls: ") || notTLS(err))
+}
+
+// notTLS returns true if err says that the server answered a TLS handshake
+// with plain HTTP. net/http reports this in its own error rather than
+// returning the tls.RecordHeaderError.
+func notTLS(err error) bool {
+	return strings.Contains(err.Error(), "server gave HTTP response to HTTPS client")
+}
+
+// DescribeTLSError explains why a TLS connection failed and what can be done
+// about it.
+func DescribeTLSError(err error) string {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		recordHeader     tls.RecordHeaderError
+	)
+
+	switch {
+	case errors.As(err, &unknownAuthority):
+		issuer := "an unknown authority"
+		if unknownAuthority.Cert != nil {
+			issuer = fmt.Sprintf("%q", unknownAuthority.Cert.Issuer.String())
+		}
+
+		return fmt.Sprintf("the certificate is signed by %s which is not trusted. If you are behind a proxy that intercepts TLS, set ca_file to its CA bundle", issuer)
+	case errors.As(err, &hostname):
+		return fmt.Sprintf("the certificate is not valid for %s. A proxy may be intercepting the connection", hostname.Host)
+	case errors.As(err, &invalid):
+		if invalid.Reason == x509.Expired {
+			return "the certificate has expired or is not yet valid. Check that the system clock is correct"
+		}
+
+		return fmt.Sprintf("the certificate is not valid: %s", invalid.Error())
+	case errors.As(err, &recordHeader), notTLS(err):
+		return "the server did not respond with TLS. Check that HTTPS_PROXY points to an http:// proxy URL"
+	case strings.Contains(err.Error(), "certificate required"), strings.Contains(err.Error(), "bad certificate"):
+		return "the server rejected the client certificate. Check client_cert and client_key"
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,315 @@
+package network
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+)
+
+// writePEM writes blocks of type typ to a file in a temp dir and returns its
+// path.
+func writePEM(t *testing.T, name, typ string, blocks ...[]byte) string {
+	t.Helper()
+
+	var data []byte
+	for _, b := range blocks {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b})...)
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// newKeyPair creates a self signed client certificate and writes it and its
+// key to PEM files.
+func newKeyPair(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pdk client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writePEM(t, "client.crt", "CERTIFICATE", der), writePEM(t, "client.key", "EC PRIVATE KEY", keyDER)
+}
+
+// serverCA writes the certificate of server to a CA file.
+func serverCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	return writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+}
+
+func get(t *testing.T, cfg *tls.Config, url string) error {
+	t.Helper()
+
+	transport := DefaultTransport()
+	transport.TLSClientConfig = cfg
+
+	response, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+func TestTLSConfigCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cfg, err := TLSConfig(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = get(t, cfg, server.URL)
+	if !IsTLSError(err) {
+		t.Fatalf("without ca_file got error %v, want a TLS error", err)
+	}
+	if got := DescribeTLSError(err); !strings.Contains(got, "which is not trusted") || !strings.Contains(got, "set ca_file") {
+		t.Errorf("DescribeTLSError() = %q, want it to suggest ca_file", got)
+	}
+
+	cfg, err = TLSConfig(Options{CAFile: serverCA(t, server)})
+	if err != nil {
+		t.Fatalf("TLSConfig() error = %v", err)
+	}
+
+	if err := get(t, cfg, server.URL); err != nil {
+		t.Errorf("with ca_file got error %v", err)
+	}
+
+	if err := CheckHost(context.Background(), &http.Transport{TLSClientConfig: cfg}, server.Listener.Addr().String()); err != nil {
+		t.Errorf("CheckHost() error = %v", err)
+	}
+}
+
+func TestCACertificates(t *testing.T) {
+	first := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer first.Close()
+	second := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer second.Close()
+
+	bundle := writePEM(t, "bundle.pem", "CERTIFICATE", first.Certificate().Raw, second.Certificate().Raw)
+
+	count, err := CACertificates(bundle)
+	if err != nil {
+		t.Fatalf("CACertificates() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("CACertificates() = %d, want 2", count)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	certFile, keyFile := newKeyPair(t)
+	_, otherKey := newKeyPair(t)
+
+	tests := []struct {
+		name string
+		opts Options
+		kind pdk_errors.Kind
+		want string
+	}{
+		{
+			name: "missing ca_file",
+			opts: Options{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			kind: pdk_errors.TLS,
+			want: "could not read ca_file",
+		},
+		{
+			name: "ca_file without certificates",
+			opts: Options{CAFile: writePEM(t, "key.pem", "EC PRIVATE KEY", []byte("key"))},
+			kind: pdk_errors.TLS,
+			want: "does not contain any PEM certificates",
+		},
+		{
+			name: "invalid certificate in ca_file",
+			opts: Options{CAFile: writePEM(t, "bad.pem", "CERTIFICATE", []byte("not a certificate"))},
+			kind: pdk_errors.TLS,
+			want: "invalid certificate in ca_file",
+		},
+		{
+			name: "client_cert without client_key",
+			opts: Options{ClientCert: certFile},
+			kind: pdk_errors.InvalidConfig,
+			want: "client_cert and client_key must be set together",
+		},
+		{
+			name: "client_key without client_cert",
+			opts: Options{ClientKey: keyFile},
+			kind: pdk_errors.InvalidConfig,
+			want: "client_cert and client_key must be set together",
+		},
+		{
+			name: "client_key does not match client_cert",
+			opts: Options{ClientCert: certFile, ClientKey: otherKey},
+			kind: pdk_errors.TLS,
+			want: "could not load client certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TLSConfig(tt.opts)
+			if err == nil {
+				t.Fatal("TLSConfig() returned no error")
+			}
+
+			if kind := pdk_errors.KindOf(err); kind != tt.kind {
+				t.Errorf("KindOf() = %v, want %v", kind, tt.kind)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLSConfigClientCert(t *testing.T) {
+	var presented int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.StoreInt32(&presented, int32(len(r.TLS.PeerCertificates)))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	ca := serverCA(t, server)
+
+	cfg, err := TLSConfig(Options{CAFile: ca})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = get(t, cfg, server.URL)
+	if err == nil {
+		t.Fatal("without a client certificate got no error")
+	}
+	if got, want := DescribeTLSError(err), "the server rejected the client certificate"; !strings.Contains(got, want) {
+		t.Errorf("DescribeTLSError() = %q, want it to contain %q", got, want)
+	}
+
+	certFile, keyFile := newKeyPair(t)
+	cfg, err = TLSConfig(Options{CAFile: ca, ClientCert: certFile, ClientKey: keyFile})
+	if err != nil {
+		t.Fatalf("TLSConfig() error = %v", err)
+	}
+	if len(cfg.Certificates) != 1 {
+		t.Fatalf("got %d client certificates, want 1", len(cfg.Certificates))
+	}
+
+	if err := get(t, cfg, server.URL); err != nil {
+		t.Fatalf("with a client certificate got error %v", err)
+	}
+	if n := atomic.LoadInt32(&presented); n != 1 {
+		t.Errorf("server saw %d client certificates, want 1", n)
+	}
+}
+
+func TestDescribeTLSError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+
+	cfg, err := TLSConfig(Options{CAFile: serverCA(t, server)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The httptest certificate is valid for example.com and 127.0.0.1.
+	wrongHost := cfg.Clone()
+	wrongHost.ServerName = "pdk.invalid"
+
+	expired := cfg.Clone()
+	expired.Time = func() time.Time { return time.Now().AddDate(100, 0, 0) }
+
+	tests := []struct {
+		name string
+		cfg  *tls.Config
+		url  string
+		want string
+	}{
+		{
+			name: "wrong host",
+			cfg:  wrongHost,
+			url:  server.URL,
+			want: "the certificate is not valid for pdk.invalid",
+		},
+		{
+			name: "expired",
+			cfg:  expired,
+			url:  server.URL,
+			want: "the certificate has expired or is not yet valid",
+		},
+		{
+			name: "not TLS",
+			cfg:  cfg,
+			url:  "https://" + plain.Listener.Addr().String(),
+			want: "the server did not respond with TLS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := get(t, tt.cfg, tt.url)
+			if !IsTLSError(err) {
+				t.Fatalf("got error %v, want a TLS error", err)
+			}
+
+			if got := DescribeTLSError(err); !strings.Contains(got, tt.want) {
+				t.Errorf("DescribeTLSError() = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckHostTLSError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	err := CheckHost(context.Background(), DefaultTransport(), server.Listener.Addr().String())
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.TLS {
+		t.Fatalf("KindOf(%v) = %v, want %v", err, kind, pdk_errors.TLS)
+	}
+}
@@ -0,0 +1,45 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+)
+
+// ErrOffline is returned when a connection is attempted while the pdk is in
+// offline mode.
+var ErrOffline = pdk_errors.New(pdk_errors.Offline, "network access is disabled in offline mode")
+
+var offline int32
+
+// SetOffline enables or disables offline mode for the whole process. While
+// it is enabled every transport returned by this package refuses to dial and
+// the git fetcher refuses remote repositories.
+func SetOffline(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+
+	atomic.StoreInt32(&offline, v)
+}
+
+// Offline returns true if offline mode is enabled.
+func Offline() bool {
+	return atomic.LoadInt32(&offline) == 1
+}
+
+// dialContext refuses every connection in offline mode. It is checked when
+// a connection is made rather than when a transport is created so that
+// transports created before offline mode was enabled are covered too.
+func dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if Offline() {
+			return nil, ErrOffline
+		}
+
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
@@ -0,0 +1,57 @@
+package network
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+)
+
+func TestOfflineRefusesDial(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+
+	// The transport is created before offline mode is enabled to show that
+	// the check is made when dialling.
+	client := &http.Client{Transport: DefaultTransport()}
+
+	SetOffline(true)
+	defer SetOffline(false)
+
+	_, err := client.Get(server.URL)
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrOffline)
+	}
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.Offline {
+		t.Errorf("KindOf() = %v, want %v", kind, pdk_errors.Offline)
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("server received %d requests in offline mode", n)
+	}
+}
+
+func TestOnlineDial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &http.Client{Transport: DefaultTransport()}
+
+	SetOffline(true)
+	SetOffline(false)
+
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want %d", response.StatusCode, http.StatusOK)
+	}
+}
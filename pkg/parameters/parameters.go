@@ -0,0 +1,326 @@
+// Package parameters implements the typed parameters that templates declare
+// in the parameters section of pct-config.yml. Values can be given up front,
+// read from values files or asked for interactively.
+package parameters
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Type is the type of a parameter.
+type Type string
+
+const (
+	String Type = "string"
+	Int    Type = "int"
+	Bool   Type = "bool"
+	Enum   Type = "enum"
+	List   Type = "list"
+)
+
+// Parameter describes a single value that a template needs.
+type Parameter struct {
+	// Name is the key of the parameter in the parameters section.
+	Name string `yaml:"-"`
+
+	// Type defaults to string.
+	Type        Type        `yaml:"type"`
+	Description string      `yaml:"description"`
+	Prompt      string      `yaml:"prompt"`
+	Default     interface{} `yaml:"default"`
+	Required    bool        `yaml:"required"`
+
+	// Choices are the values allowed for an enum.
+	Choices []string `yaml:"choices"`
+
+	// Pattern is a regular expression that strings and the items of lists
+	// must match in full.
+	Pattern string `yaml:"pattern"`
+
+	// Min and Max limit the value of an int.
+	Min *int `yaml:"min"`
+	Max *int `yaml:"max"`
+
+	// When makes the parameter conditional on another one. It is either the
+	// name of a parameter, which must be truthy, the name prefixed with !,
+	// which must not be, or a comparison in the form name == value or
+	// name != value.
+	When string `yaml:"when"`
+}
+
+// Label returns the text shown when asking for the parameter.
+func (p Parameter) Label() string {
+	if p.Prompt != "" {
+		return p.Prompt
+	}
+
+	if p.Description != "" {
+		return p.Description
+	}
+
+	return p.Name
+}
+
+// Zero returns the empty value of the type of the parameter. It is used for
+// parameters that have no value so that templates can still refer to them.
+func (p Parameter) Zero() interface{} {
+	switch p.Type {
+	case Int:
+		return 0
+	case Bool:
+		return false
+	case List:
+		return []string{}
+	default:
+		return ""
+	}
+}
+
+// DeclarationError is returned by Parse when a parameter is declared
+// incorrectly. Line and Column point at the name of the parameter.
+type DeclarationError struct {
+	Name   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *DeclarationError) Error() string {
+	return fmt.Sprintf("line %d: parameter %s: %v", e.Line, e.Name, e.Err)
+}
+
+func (e *DeclarationError) Unwrap() error {
+	return e.Err
+}
+
+// Parse reads the parameters section of a pct-config.yml. Parameters are
+// returned in the order that they are declared.
+func Parse(data []byte) ([]Parameter, error) {
+	var doc struct {
+		Parameters yaml.Node `yaml:"parameters"`
+	}
+
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	node := doc.Parameters
+	if node.Kind == 0 {
+		return nil, nil
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: parameters must be a map", node.Line)
+	}
+
+	params := make([]Parameter, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+
+		var p Parameter
+		err := node.Content[i+1].Decode(&p)
+		if err == nil {
+			p.Name = key.Value
+			if p.Type == "" {
+				p.Type = String
+			}
+
+			err = p.check()
+		}
+
+		if err != nil {
+			return nil, &DeclarationError{Name: key.Value, Line: key.Line, Column: key.Column, Err: err}
+		}
+
+		params = append(params, p)
+	}
+
+	return params, nil
+}
+
+// check validates the declaration of the parameter itself.
+func (p Parameter) check() error {
+	switch p.Type {
+	case String, Int, Bool, List:
+	case Enum:
+		if len(p.Choices) == 0 {
+			return fmt.Errorf("enums need choices")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", p.Type)
+	}
+
+	if p.Pattern != "" {
+		if _, err := regexp.Compile(p.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+
+	if p.Default != nil {
+		if _, err := p.Coerce(p.Default); err != nil {
+			return fmt.Errorf("invalid default: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Coerce converts v to the type of the parameter and validates it. v may be
+// a string, as given with --set or typed at a prompt, or a value decoded
+// from YAML.
+func (p Parameter) Coerce(v interface{}) (interface{}, error) {
+	switch p.Type {
+	case Int:
+		n, err := toInt(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.Min != nil && n < *p.Min {
+			return nil, fmt.Errorf("%d is less than the minimum of %d", n, *p.Min)
+		}
+
+		if p.Max != nil && n > *p.Max {
+			return nil, fmt.Errorf("%d is greater than the maximum of %d", n, *p.Max)
+		}
+
+		return n, nil
+	case Bool:
+		return toBool(v)
+	case Enum:
+		s := fmt.Sprint(v)
+		for _, c := range p.Choices {
+			if c == s {
+				return s, nil
+			}
+		}
+
+		return nil, fmt.Errorf("%q is not one of %s", s, strings.Join(p.Choices, ", "))
+	case List:
+		items, err := toList(v)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			if err := p.match(item); err != nil {
+				return nil, err
+			}
+		}
+
+		return items, nil
+	default:
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case int, float64, bool:
+			s = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("expected a string but got %v", v)
+		}
+
+		if err := p.match(s); err != nil {
+			return nil, err
+		}
+
+		return s, nil
+	}
+}
+
+func (p Parameter) match(s string) error {
+	if p.Pattern == "" {
+		return nil
+	}
+
+	if !regexp.MustCompile(`^(?:` + p.Pattern + `)$`).MatchString(s) {
+		return fmt.Errorf("%q does not match %s", s, p.Pattern)
+	}
+
+	return nil
+}
+
+// Format returns v in the form that Coerce accepts as a string. It is used
+// to show defaults at a prompt.
+func Format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, ",")
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toInt(v interface{}) (int, error) {
+	switch v := v.(type) {
+	case int:
+		return v, nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("%v is not a whole number", v)
+		}
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a whole number", v)
+		}
+		return n, nil
+	}
+
+	return 0, fmt.Errorf("expected a whole number but got %v", v)
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "y", "1":
+			return true, nil
+		case "false", "no", "n", "0":
+			return false, nil
+		}
+	}
+
+	return false, fmt.Errorf("expected true or false but got %v", v)
+}
+
+func toList(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return items, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return []string{}, nil
+		}
+
+		items := strings.Split(v, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("expected a list but got %v", v)
+}
@@ -0,0 +1,127 @@
+package parameters
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		name  string
+		param Parameter
+		value interface{}
+		want  interface{}
+		err   string
+	}{
+		{name: "string", param: Parameter{Type: String}, value: "hello", want: "hello"},
+		{name: "string from a number", param: Parameter{Type: String}, value: 42, want: "42"},
+		{name: "string from a map", param: Parameter{Type: String}, value: map[string]interface{}{}, err: "expected a string"},
+		{name: "pattern", param: Parameter{Type: String, Pattern: "[a-z]+"}, value: "abc", want: "abc"},
+		{name: "pattern is anchored at the start", param: Parameter{Type: String, Pattern: "[a-z]+"}, value: "1abc", err: `"1abc" does not match [a-z]+`},
+		{name: "pattern is anchored at the end", param: Parameter{Type: String, Pattern: "[a-z]+"}, value: "abc1", err: `"abc1" does not match [a-z]+`},
+		{name: "pattern alternatives are anchored", param: Parameter{Type: String, Pattern: "a|b"}, value: "ab", err: `"ab" does not match a|b`},
+		{name: "pattern with its own anchors", param: Parameter{Type: String, Pattern: "^[a-z]+$"}, value: "abc", want: "abc"},
+
+		{name: "int from a string", param: Parameter{Type: Int}, value: " 8 ", want: 8},
+		{name: "int from an int", param: Parameter{Type: Int}, value: 8, want: 8},
+		{name: "int from a whole float", param: Parameter{Type: Int}, value: 8.0, want: 8},
+		{name: "int from a fraction", param: Parameter{Type: Int}, value: 8.5, err: "8.5 is not a whole number"},
+		{name: "int from text", param: Parameter{Type: Int}, value: "eight", err: `"eight" is not a whole number`},
+		{name: "int below min", param: Parameter{Type: Int, Min: intPtr(1)}, value: "0", err: "0 is less than the minimum of 1"},
+		{name: "int above max", param: Parameter{Type: Int, Max: intPtr(10)}, value: 11, err: "11 is greater than the maximum of 10"},
+		{name: "int within limits", param: Parameter{Type: Int, Min: intPtr(1), Max: intPtr(10)}, value: "10", want: 10},
+
+		{name: "bool", param: Parameter{Type: Bool}, value: true, want: true},
+		{name: "bool from yes", param: Parameter{Type: Bool}, value: "Yes", want: true},
+		{name: "bool from 0", param: Parameter{Type: Bool}, value: "0", want: false},
+		{name: "bool from text", param: Parameter{Type: Bool}, value: "maybe", err: "expected true or false but got maybe"},
+
+		{name: "enum", param: Parameter{Type: Enum, Choices: []string{"MIT", "Apache-2.0"}}, value: "MIT", want: "MIT"},
+		{name: "enum is case sensitive", param: Parameter{Type: Enum, Choices: []string{"MIT", "Apache-2.0"}}, value: "mit", err: `"mit" is not one of MIT, Apache-2.0`},
+
+		{name: "list from a string", param: Parameter{Type: List}, value: "a, b ,c", want: []string{"a", "b", "c"}},
+		{name: "list from an empty string", param: Parameter{Type: List}, value: " ", want: []string{}},
+		{name: "list from YAML", param: Parameter{Type: List}, value: []interface{}{"a", 1}, want: []string{"a", "1"}},
+		{name: "list from a number", param: Parameter{Type: List}, value: 1, err: "expected a list but got 1"},
+		{name: "list items match the pattern", param: Parameter{Type: List, Pattern: "[a-z]+"}, value: "a,b1", err: `"b1" does not match [a-z]+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.param.Coerce(tt.value)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Coerce(%v) error = %v, want %q", tt.value, err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Coerce(%v) error = %v", tt.value, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Coerce(%v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	config := `parameters:
+  name:
+    pattern: "^[a-z]+$"
+    required: true
+  count:
+    type: int
+    default: 3
+`
+
+	params, err := Parse([]byte(config))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []Parameter{
+		{Name: "name", Type: String, Pattern: "^[a-z]+$", Required: true},
+		{Name: "count", Type: Int, Default: 3},
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("Parse() = %+v, want %+v", params, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+		line   int
+	}{
+		{name: "unknown type", config: "parameters:\n  a:\n    type: float\n", err: `unknown type "float"`, line: 2},
+		{name: "enum without choices", config: "parameters:\n  a:\n    type: enum\n", err: "enums need choices", line: 2},
+		{name: "invalid pattern", config: "parameters:\n  a: {}\n  b:\n    pattern: '['\n", err: "invalid pattern", line: 3},
+		{name: "invalid default", config: "parameters:\n  a:\n    type: int\n    default: many\n", err: "invalid default", line: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.config))
+
+			var declErr *DeclarationError
+			if !errors.As(err, &declErr) {
+				t.Fatalf("Parse() error = %v, want a *DeclarationError", err)
+			}
+
+			if declErr.Line != tt.line || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse() error = %v on line %d, want %q on line %d", err, declErr.Line, tt.err, tt.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package parameters
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Prompter asks for the value of a parameter. validate is called with each
+// answer and the prompter should keep asking until it returns nil. An empty
+// answer selects the default.
+type Prompter interface {
+	Ask(p Parameter, defaultValue string, validate func(string) error) (string, error)
+}
+
+// Resolve works out the value of every visible parameter. Values given in
+// provided take precedence. Other parameters are asked for with prompter if
+// it is not nil, or take their default. An error is returned for required
+// parameters without a value and for values that do not belong to a
+// parameter. Values given for parameters that are hidden by their when
+// condition are ignored with a warning.
+func Resolve(params []Parameter, provided map[string]interface{}, prompter Prompter) (map[string]interface{}, error) {
+	known := make(map[string]bool, len(params))
+	for _, p := range params {
+		known[p.Name] = true
+	}
+
+	var unknown []string
+	for name := range provided {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown parameters: %s", strings.Join(unknown, ", "))
+	}
+
+	values := make(map[string]interface{}, len(params))
+	for _, p := range params {
+		visible, err := Visible(p, values)
+		if err != nil {
+			return nil, err
+		}
+
+		if !visible {
+			if _, ok := provided[p.Name]; ok {
+				log.Warn().Msgf("ignoring the value of %s because its condition %q is not met", p.Name, p.When)
+			}
+			continue
+		}
+
+		value, err := resolveOne(p, provided, prompter)
+		if err != nil {
+			return nil, err
+		}
+
+		if value != nil {
+			values[p.Name] = value
+		}
+	}
+
+	return values, nil
+}
+
+func resolveOne(p Parameter, provided map[string]interface{}, prompter Prompter) (interface{}, error) {
+	if v, ok := provided[p.Name]; ok {
+		value, err := p.Coerce(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", p.Name, err)
+		}
+
+		return value, nil
+	}
+
+	if prompter != nil {
+		var value interface{}
+		answer, err := prompter.Ask(p, Format(p.Default), func(answer string) error {
+			if answer == "" && p.Default == nil && p.Required {
+				return fmt.Errorf("a value is required")
+			}
+
+			if answer == "" {
+				return nil
+			}
+
+			v, err := p.Coerce(answer)
+			value = v
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if answer != "" {
+			return value, nil
+		}
+	}
+
+	if p.Default != nil {
+		return p.Coerce(p.Default)
+	}
+
+	if p.Required {
+		return nil, fmt.Errorf("parameter %s is required. Set it with --set %s=<value>", p.Name, p.Name)
+	}
+
+	return nil, nil
+}
+
+// Visible returns true if the when condition of p is met by values.
+func Visible(p Parameter, values map[string]interface{}) (bool, error) {
+	when := strings.TrimSpace(p.When)
+	if when == "" {
+		return true, nil
+	}
+
+	for _, op := range []string{"==", "!="} {
+		if i := strings.Index(when, op); i != -1 {
+			name := strings.TrimSpace(when[:i])
+			want := strings.Trim(strings.TrimSpace(when[i+len(op):]), `"'`)
+			equal := Format(values[name]) == want
+			return equal == (op == "=="), nil
+		}
+	}
+
+	negate := strings.HasPrefix(when, "!")
+	name := strings.TrimSpace(strings.TrimPrefix(when, "!"))
+	if strings.ContainsAny(name, " \t") {
+		return false, fmt.Errorf("parameter %s: invalid when condition %q", p.Name, p.When)
+	}
+
+	return truthy(values[name]) != negate, nil
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case string:
+		return v != ""
+	case []string:
+		return len(v) > 0
+	}
+
+	return true
+}
+
+// ReadValuesFile reads a YAML file that maps parameter names to values.
+func ReadValuesFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("invalid values file %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+// Provided merges the values in files with the values given in the form
+// name=value in pairs. Later files take precedence over earlier ones and
+// pairs take precedence over every file.
+func Provided(files, pairs []string) (map[string]interface{}, error) {
+	provided := map[string]interface{}{}
+
+	for _, file := range files {
+		values, err := ReadValuesFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range values {
+			provided[k] = v
+		}
+	}
+
+	values, err := ParseSet(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range values {
+		provided[k] = v
+	}
+
+	return provided, nil
+}
+
+// ParseSet parses values given in the form name=value.
+func ParseSet(pairs []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid value %q. Values must be in the form name=value", pair)
+		}
+
+		values[name] = value
+	}
+
+	return values, nil
+}
+
+type terminalPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// Ask writes the label of p, its choices and default, and reads an answer.
+func (t *terminalPrompter) Ask(p Parameter, defaultValue string, validate func(string) error) (string, error) {
+	for {
+		label := p.Label()
+		if len(p.Choices) > 0 {
+			label = fmt.Sprintf("%s (%s)", label, strings.Join(p.Choices, "/"))
+		}
+
+		if defaultValue != "" {
+			label = fmt.Sprintf("%s [%s]", label, defaultValue)
+		}
+
+		fmt.Fprintf(t.out, "%s: ", label)
+
+		answer, err := t.in.ReadString('\n')
+		if err != nil && (err != io.EOF || answer == "") {
+			return "", fmt.Errorf("could not read a value for %s: %w", p.Name, err)
+		}
+
+		answer = strings.TrimSpace(answer)
+		if err := validate(answer); err != nil {
+			fmt.Fprintf(t.out, "  %v\n", err)
+			continue
+		}
+
+		return answer, nil
+	}
+}
+
+// NewPrompter returns a Prompter that asks questions on out and reads the
+// answers from in.
+func NewPrompter(in io.Reader, out io.Writer) Prompter {
+	return &terminalPrompter{in: bufio.NewReader(in), out: out}
+}
+
+// Data returns the data that a template is rendered with: its defaults,
+// overridden by values. Parameters without a value are set to the empty
+// value of their type so that templates can still refer to them.
+func Data(defaults map[string]interface{}, params []Parameter, values map[string]interface{}) map[string]interface{} {
+	data := map[string]interface{}{}
+	for k, v := range defaults {
+		data[k] = v
+	}
+	for _, p := range params {
+		data[p.Name] = p.Zero()
+	}
+	for k, v := range values {
+		data[k] = v
+	}
+
+	return data
+}
@@ -0,0 +1,276 @@
+package parameters
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// fakePrompter answers each question with the next answer, or with the
+// default when it runs out. Answers that do not validate are skipped, as a
+// user would be asked again.
+type fakePrompter struct {
+	answers []string
+	asked   []string
+}
+
+func (f *fakePrompter) Ask(p Parameter, defaultValue string, validate func(string) error) (string, error) {
+	f.asked = append(f.asked, p.Name)
+
+	for len(f.answers) > 0 {
+		answer := f.answers[0]
+		f.answers = f.answers[1:]
+
+		if err := validate(answer); err == nil {
+			return answer, nil
+		}
+	}
+
+	return "", validate("")
+}
+
+func TestVisible(t *testing.T) {
+	values := map[string]interface{}{
+		"private": true,
+		"public":  false,
+		"license": "MIT",
+		"count":   0,
+		"tags":    []string{},
+	}
+
+	tests := []struct {
+		when string
+		want bool
+		err  bool
+	}{
+		{when: "", want: true},
+		{when: "private", want: true},
+		{when: "public", want: false},
+		{when: "!private", want: false},
+		{when: "!public", want: true},
+		{when: "missing", want: false},
+		{when: "!missing", want: true},
+		{when: "count", want: false},
+		{when: "tags", want: false},
+		{when: "license == MIT", want: true},
+		{when: "license == 'MIT'", want: true},
+		{when: `license != "MIT"`, want: false},
+		{when: "license == Apache-2.0", want: false},
+		{when: "private == true", want: true},
+		{when: "license is MIT", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			got, err := Visible(Parameter{Name: "p", When: tt.when}, values)
+			if (err != nil) != tt.err {
+				t.Fatalf("Visible(%q) error = %v, want error %t", tt.when, err, tt.err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Visible(%q) = %t, want %t", tt.when, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	params := []Parameter{
+		{Name: "name", Type: String, Pattern: "[a-z]+", Required: true},
+		{Name: "license", Type: Enum, Choices: []string{"MIT", "Apache-2.0"}, Default: "MIT"},
+		{Name: "private", Type: Bool, Default: false},
+		{Name: "owner", Type: String, When: "!private", Default: "puppet"},
+		{Name: "tags", Type: List},
+	}
+
+	tests := []struct {
+		name     string
+		provided map[string]interface{}
+		prompter *fakePrompter
+		want     map[string]interface{}
+		asked    []string
+		err      string
+	}{
+		{
+			name:     "defaults",
+			provided: map[string]interface{}{"name": "demo"},
+			want:     map[string]interface{}{"name": "demo", "license": "MIT", "private": false, "owner": "puppet"},
+		},
+		{
+			name:     "provided values are coerced",
+			provided: map[string]interface{}{"name": "demo", "private": "yes", "tags": "a,b"},
+			want:     map[string]interface{}{"name": "demo", "license": "MIT", "private": true, "tags": []string{"a", "b"}},
+		},
+		{
+			name:     "hidden parameters have no value",
+			provided: map[string]interface{}{"name": "demo", "private": true, "owner": "someone"},
+			want:     map[string]interface{}{"name": "demo", "license": "MIT", "private": true},
+		},
+		{
+			name:     "missing required value",
+			provided: map[string]interface{}{},
+			err:      "parameter name is required. Set it with --set name=<value>",
+		},
+		{
+			name:     "unknown values",
+			provided: map[string]interface{}{"name": "demo", "zzz": 1, "aaa": 2},
+			err:      "unknown parameters: aaa, zzz",
+		},
+		{
+			name:     "invalid enum",
+			provided: map[string]interface{}{"name": "demo", "license": "GPL"},
+			err:      `invalid value for license: "GPL" is not one of MIT, Apache-2.0`,
+		},
+		{
+			name:     "invalid pattern",
+			provided: map[string]interface{}{"name": "Demo"},
+			err:      `invalid value for name: "Demo" does not match [a-z]+`,
+		},
+		{
+			name:     "prompted values",
+			provided: map[string]interface{}{"license": "Apache-2.0"},
+			prompter: &fakePrompter{answers: []string{"", "Demo", "demo", "", "", "x,y"}},
+			want:     map[string]interface{}{"name": "demo", "license": "Apache-2.0", "private": false, "owner": "puppet", "tags": []string{"x", "y"}},
+			asked:    []string{"name", "private", "owner", "tags"},
+		},
+		{
+			name:     "prompts only for visible parameters",
+			provided: map[string]interface{}{"name": "demo"},
+			prompter: &fakePrompter{answers: []string{"Apache-2.0", "true"}},
+			want:     map[string]interface{}{"name": "demo", "license": "Apache-2.0", "private": true},
+			asked:    []string{"license", "private", "tags"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompter Prompter
+			if tt.prompter != nil {
+				prompter = tt.prompter
+			}
+
+			got, err := Resolve(params, tt.provided, prompter)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Resolve() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %#v, want %#v", got, tt.want)
+			}
+
+			if tt.prompter != nil && !reflect.DeepEqual(tt.prompter.asked, tt.asked) {
+				t.Errorf("asked for %v, want %v", tt.prompter.asked, tt.asked)
+			}
+		})
+	}
+}
+
+func TestResolveWarnsAboutHiddenValues(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = logger }()
+
+	params := []Parameter{
+		{Name: "private", Type: Bool},
+		{Name: "owner", Type: String, When: "!private"},
+	}
+
+	values, err := Resolve(params, map[string]interface{}{"private": true, "owner": "someone"}, nil)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if _, ok := values["owner"]; ok {
+		t.Errorf("owner = %v, want no value", values["owner"])
+	}
+
+	if want := `ignoring the value of owner because its condition \"!private\" is not met`; !strings.Contains(buf.String(), want) {
+		t.Errorf("got log %q, want it to contain %q", buf.String(), want)
+	}
+}
+
+func TestProvided(t *testing.T) {
+	dir := t.TempDir()
+
+	first := filepath.Join(dir, "first.yml")
+	if err := os.WriteFile(first, []byte("name: first\nlicense: MIT\ntags: [a, b]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	second := filepath.Join(dir, "second.yml")
+	if err := os.WriteFile(second, []byte("name: second\nprivate: true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Provided([]string{first, second}, []string{"private=false", "owner=a=b"})
+	if err != nil {
+		t.Fatalf("Provided() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"name":    "second",
+		"license": "MIT",
+		"tags":    []interface{}{"a", "b"},
+		"private": "false",
+		"owner":   "a=b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Provided() = %#v, want %#v", got, want)
+	}
+}
+
+func TestProvidedErrors(t *testing.T) {
+	invalid := filepath.Join(t.TempDir(), "invalid.yml")
+	if err := os.WriteFile(invalid, []byte("- not\n- a map\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		files []string
+		pairs []string
+		err   string
+	}{
+		{name: "missing file", files: []string{filepath.Join(t.TempDir(), "missing.yml")}, err: "no such file"},
+		{name: "invalid file", files: []string{invalid}, err: "invalid values file"},
+		{name: "pair without =", pairs: []string{"name"}, err: `invalid value "name". Values must be in the form name=value`},
+		{name: "pair without a name", pairs: []string{"=demo"}, err: `invalid value "=demo"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Provided(tt.files, tt.pairs)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Provided() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestData(t *testing.T) {
+	params := []Parameter{
+		{Name: "name", Type: String},
+		{Name: "count", Type: Int},
+		{Name: "tags", Type: List},
+	}
+
+	got := Data(map[string]interface{}{"name": "default", "summary": "A module"}, params, map[string]interface{}{"count": 2})
+	want := map[string]interface{}{"name": "", "summary": "A module", "count": 2, "tags": []string{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Data() = %#v, want %#v", got, want)
+	}
+}
@@ -3,27 +3,77 @@ package pct_config_processor // nolint
 
 import (
 	"bytes"
-	"fmt"
+	"errors"
+	"path/filepath"
 
+	"github.com/chelnak/pdk/pkg/hooks"
+	"github.com/chelnak/pdk/pkg/parameters"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/tool"
 	"github.com/puppetlabs/pct/pkg/config_processor"
 	"github.com/puppetlabs/pct/pkg/install"
 	"github.com/spf13/afero"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 // PuppetContentTemplateInfo is the housing struct for marshaling YAML data
 type PuppetContentTemplateInfo struct {
 	Template PuppetContentTemplate `mapstructure:"template"`
 	Defaults map[string]interface{}
+	Hooks    hooks.Hooks `mapstructure:"hooks"`
+
+	// Tool describes how to run the package. It is only set for tools. It is
+	// read separately because viper drops empty maps, such as capabilities
+	// without args.
+	Tool tool.Spec `mapstructure:"-"`
+
+	// Parameters are read separately so that their declaration order is
+	// kept.
+	Parameters []parameters.Parameter `mapstructure:"-"`
 }
 
 // PuppetContentTemplate houses the actual information about each template
 type PuppetContentTemplate struct {
 	install.ConfigParams `mapstructure:",squash"`
-	Type                 string `mapstructure:"type"`
-	Display              string `mapstructure:"display"`
-	URL                  string `mapstructure:"url"`
+	Type                 TemplateType `mapstructure:"type"`
+	Display              string       `mapstructure:"display"`
+	URL                  string       `mapstructure:"url"`
+}
+
+// TemplateType is the kind of package that a pct-config.yml describes.
+type TemplateType string
+
+const (
+	// Project templates create a new project in a directory of its own.
+	Project TemplateType = "project"
+
+	// Item templates add files to an existing module, such as a class or a
+	// task.
+	Item TemplateType = "item"
+
+	// Tool packages are run against content by 'pdk exec' and 'pdk validate'.
+	// They do not create content.
+	Tool TemplateType = "tool"
+)
+
+// TemplateTypes returns the names of every template type.
+func TemplateTypes() []string {
+	return []string{string(Project), string(Item), string(Tool)}
+}
+
+// Kind returns the type of the template. Templates that do not set one are
+// projects.
+func (t PuppetContentTemplate) Kind() TemplateType {
+	if t.Type == "" {
+		return Project
+	}
+
+	return t.Type
 }
+
+// PctConfigProcessor reads and validates pct-config.yml files. It holds no
+// state of its own, so it is safe for concurrent use.
 type PctConfigProcessor struct {
 	AFS *afero.Afero
 }
@@ -47,30 +97,79 @@ func (p *PctConfigProcessor) GetConfigMetadata(configFile string) (metadata conf
 	return metadata, nil
 }
 
+// CheckConfig validates configFile against the pct-config.yml schema. A
+// *ValidationError listing every problem is returned if it is not valid.
+// The files next to it are then checked according to the type of the
+// template: projects and items need a content directory and tools need the
+// commands that they declare in the package.
 func (p *PctConfigProcessor) CheckConfig(configFile string) error {
+	problems, err := p.Lint(configFile)
+	if err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{File: configFile, Problems: problems}
+	}
+
 	info, err := p.ReadConfig(configFile)
 	if err != nil {
 		return err
 	}
 
-	msg := fmt.Sprintf("The following attributes are missing in %s:\n", configFile)
-	orig := msg
-	// These parts are essential for build and deployment.
+	root := filepath.Dir(configFile)
+	switch info.Template.Kind() {
+	case Project, Item:
+		if ok, _ := p.AFS.DirExists(filepath.Join(root, "content")); !ok {
+			return pdk_errors.New(pdk_errors.InvalidTemplate, "no 'content' dir found in %v", root)
+		}
+	case Tool:
+		for _, command := range []string{info.Tool.Binary, info.Tool.Entrypoint} {
+			path, local, err := tool.InPackage(command, root)
+			if err != nil {
+				return pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "invalid tool in %v", root)
+			}
 
-	if info.Template.Id == "" {
-		msg = msg + "  * id\n"
+			if local {
+				if ok, _ := p.AFS.Exists(path); !ok {
+					return pdk_errors.New(pdk_errors.InvalidTemplate, "%s does not exist in %v", command, root)
+				}
+			}
+		}
 	}
-	if info.Template.Author == "" {
-		msg = msg + "  * author\n"
+
+	return nil
+}
+
+// Lint returns every problem with configFile. An error is only returned if
+// the file could not be read.
+func (p *PctConfigProcessor) Lint(configFile string) ([]Problem, error) {
+	fileBytes, err := p.AFS.ReadFile(configFile)
+	if err != nil {
+		return nil, err
 	}
-	if info.Template.Version == "" {
-		msg = msg + "  * version\n"
+
+	problems := ValidateConfig(configFile, fileBytes)
+	if len(problems) > 0 {
+		return problems, nil
 	}
-	if msg != orig {
-		return fmt.Errorf(msg)
+
+	// The schema can not check that defaults suit the type of their
+	// parameter, so the declarations are checked here too.
+	if _, err := parameters.Parse(fileBytes); err != nil {
+		problem := Problem{File: configFile, Line: 1, Column: 1, Message: err.Error()}
+
+		var declErr *parameters.DeclarationError
+		if errors.As(err, &declErr) {
+			problem.Line, problem.Column = declErr.Line, declErr.Column
+			problem.Field = "parameters." + declErr.Name
+			problem.Message = declErr.Err.Error()
+		}
+
+		problems = append(problems, problem)
 	}
 
-	return nil
+	return problems, nil
 }
 
 func (p *PctConfigProcessor) ReadConfig(configFile string) (info PuppetContentTemplateInfo, err error) {
@@ -79,17 +178,33 @@ func (p *PctConfigProcessor) ReadConfig(configFile string) (info PuppetContentTe
 		return info, err
 	}
 
-	// use viper to parse the config as it knows how to work with mapstructure squash
-	viper.SetConfigType("yaml")
-	err = viper.ReadConfig(bytes.NewBuffer(fileBytes))
+	// use viper to parse the config as it knows how to work with mapstructure squash.
+	// A new instance is used for every read so that the global instance, which
+	// holds the pdk config, is never touched and reads can run concurrently.
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err = v.ReadConfig(bytes.NewBuffer(fileBytes))
+	if err != nil {
+		return info, err
+	}
+
+	err = v.Unmarshal(&info)
 	if err != nil {
 		return info, err
 	}
 
-	err = viper.Unmarshal(&info)
+	info.Parameters, err = parameters.Parse(fileBytes)
 	if err != nil {
 		return info, err
 	}
 
+	var doc struct {
+		Tool tool.Spec `yaml:"tool"`
+	}
+	if err := yaml.Unmarshal(fileBytes, &doc); err != nil {
+		return info, err
+	}
+	info.Tool = doc.Tool
+
 	return info, err
 }
@@ -0,0 +1,59 @@
+package pct_config_processor // nolint
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+)
+
+func TestReadConfigLeavesGlobalViperAlone(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("template_path", "/templates")
+	keys := viper.AllKeys()
+
+	afs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	p := &PctConfigProcessor{AFS: afs}
+
+	const count = 20
+	for i := 0; i < count; i++ {
+		config := fmt.Sprintf("template:\n  id: template%d\n  author: test\n  version: 0.1.0\ntemplate_path: /other\n", i)
+		if err := afs.WriteFile(fmt.Sprintf("/template%d/pct-config.yml", i), []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+	ids := make([]string, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			info, err := p.ReadConfig(fmt.Sprintf("/template%d/pct-config.yml", i))
+			errs[i], ids[i] = err, info.Template.Id
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < count; i++ {
+		if errs[i] != nil {
+			t.Errorf("ReadConfig() returned an error: %v", errs[i])
+		}
+		if want := fmt.Sprintf("template%d", i); ids[i] != want {
+			t.Errorf("got id %q, want %q", ids[i], want)
+		}
+	}
+
+	if got := viper.GetString("template_path"); got != "/templates" {
+		t.Errorf("got template_path %q, want %q", got, "/templates")
+	}
+	if got := viper.AllKeys(); !reflect.DeepEqual(got, keys) {
+		t.Errorf("got keys %q, want %q", got, keys)
+	}
+}
@@ -0,0 +1,287 @@
+package pct_config_processor // nolint
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is the JSON Schema that pct-config.yml files are validated against.
+//
+//go:embed schema.json
+var Schema string
+
+const schemaURL = "https://github.com/chelnak/pdk/pct-config.schema.json"
+
+var (
+	compileOnce    sync.Once
+	compiledSchema *jsonschema.Schema
+
+	yamlLinePattern     = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+	quotedPattern       = regexp.MustCompile(`'([^']*)'`)
+	shortVersionPattern = regexp.MustCompile(`^\d+(\.\d+)?$`)
+)
+
+// Problem is a single way in which a config file does not match the schema.
+type Problem struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	// Field is the dotted path of the value with the problem. It is empty
+	// for problems with the whole file.
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	location := fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	if p.Field == "" {
+		return fmt.Sprintf("%s: %s", location, p.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", location, p.Field, p.Message)
+}
+
+// ValidationError is returned when a config file does not match the schema.
+type ValidationError struct {
+	File     string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("%s is not valid:", e.File))
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ValidateConfig checks data, the contents of file, against the schema and
+// returns every problem that it finds in the order that they appear in the
+// file.
+func ValidateConfig(file string, data []byte) []Problem {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []Problem{yamlProblem(file, err)}
+	}
+
+	if len(doc.Content) == 0 {
+		return []Problem{{File: file, Line: 1, Column: 1, Message: "the file is empty"}}
+	}
+
+	index := &nodeIndex{values: map[string]*yaml.Node{}, keys: map[string]*yaml.Node{}}
+	value, err := index.convert(doc.Content[0], "")
+	if err != nil {
+		return []Problem{{File: file, Line: 1, Column: 1, Message: err.Error()}}
+	}
+
+	err = schema().Validate(value)
+	if err == nil {
+		return nil
+	}
+
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []Problem{{File: file, Line: 1, Column: 1, Message: err.Error()}}
+	}
+
+	var problems []Problem
+	for _, leaf := range leaves(validationErr) {
+		problems = append(problems, index.problem(file, leaf))
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+
+	return problems
+}
+
+func schema() *jsonschema.Schema {
+	compileOnce.Do(func() {
+		compiler := jsonschema.NewCompiler()
+		compiler.AssertFormat = true
+		if err := compiler.AddResource(schemaURL, strings.NewReader(Schema)); err != nil {
+			panic(err)
+		}
+
+		compiledSchema = compiler.MustCompile(schemaURL)
+	})
+
+	return compiledSchema
+}
+
+// leaves returns the errors at the bottom of the tree rooted at err. They
+// are the ones that describe what is actually wrong.
+func leaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+
+	var result []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		result = append(result, leaves(cause)...)
+	}
+
+	return result
+}
+
+func yamlProblem(file string, err error) Problem {
+	p := Problem{File: file, Line: 1, Column: 1, Message: err.Error()}
+
+	if match := yamlLinePattern.FindStringSubmatch(err.Error()); match != nil {
+		p.Line, _ = strconv.Atoi(match[1])
+		p.Message = match[2]
+	}
+
+	return p
+}
+
+// nodeIndex maps JSON pointers in the converted document back to the YAML
+// nodes that they came from.
+type nodeIndex struct {
+	values map[string]*yaml.Node
+	keys   map[string]*yaml.Node
+}
+
+// convert turns node in to a value that can be validated against a JSON
+// Schema and records the position of it and its children.
+func (i *nodeIndex) convert(node *yaml.Node, pointer string) (interface{}, error) {
+	i.values[pointer] = node
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		return i.convert(node.Alias, pointer)
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(node.Content)/2)
+		for n := 0; n+1 < len(node.Content); n += 2 {
+			key, value := node.Content[n], node.Content[n+1]
+			child := pointer + "/" + escapePointer(key.Value)
+			i.keys[child] = key
+
+			v, err := i.convert(value, child)
+			if err != nil {
+				return nil, err
+			}
+			m[key.Value] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		s := make([]interface{}, 0, len(node.Content))
+		for n, item := range node.Content {
+			v, err := i.convert(item, fmt.Sprintf("%s/%d", pointer, n))
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		return s, nil
+	case yaml.ScalarNode:
+		return scalar(node)
+	}
+
+	return nil, fmt.Errorf("unsupported yaml node at line %d", node.Line)
+}
+
+// scalar returns the value of a scalar node as a JSON type. Values that have
+// no JSON equivalent, such as timestamps, are returned as strings.
+func scalar(node *yaml.Node) (interface{}, error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		err := node.Decode(&b)
+		return b, err
+	case "!!int", "!!float":
+		var f float64
+		err := node.Decode(&f)
+		return f, err
+	default:
+		return node.Value, nil
+	}
+}
+
+// problem turns a validation error in to a Problem that points at the
+// relevant line and column.
+func (i *nodeIndex) problem(file string, err *jsonschema.ValidationError) Problem {
+	pointer := err.InstanceLocation
+	node := i.values[pointer]
+
+	// Point unknown properties at their key rather than at the object that
+	// holds them.
+	if strings.HasSuffix(err.KeywordLocation, "/additionalProperties") {
+		if match := quotedPattern.FindStringSubmatch(err.Message); match != nil {
+			pointer = pointer + "/" + escapePointer(match[1])
+			if key, ok := i.keys[pointer]; ok {
+				node = key
+			}
+		}
+	} else if key, ok := i.keys[pointer]; ok && strings.HasSuffix(err.KeywordLocation, "/propertyNames/pattern") {
+		node = key
+	}
+
+	p := Problem{File: file, Line: 1, Column: 1, Field: fieldName(err.InstanceLocation), Message: err.Message}
+	if node != nil {
+		p.Line, p.Column = node.Line, node.Column
+	}
+
+	if pointer == "/template/version" && node != nil {
+		p.Message = versionMessage(node.Value)
+	}
+
+	return p
+}
+
+// versionMessage explains how to fix a template version that is not a full
+// semantic version. Older releases accepted versions such as 0.1 or 1.0, so
+// the message suggests the version to change them to.
+func versionMessage(version string) string {
+	message := "must be a semantic version such as \"1.0.0\""
+	if !shortVersionPattern.MatchString(version) {
+		return message
+	}
+
+	suggestion := version + strings.Repeat(".0", 2-strings.Count(version, "."))
+	return fmt.Sprintf("%s. Versions such as %s are no longer accepted, change it to \"%s\"", message, version, suggestion)
+}
+
+// fieldName turns a JSON pointer in to a dotted path such as
+// template.version or dependencies[0].id.
+func fieldName(pointer string) string {
+	if pointer == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		if _, err := strconv.Atoi(part); err == nil {
+			fmt.Fprintf(&b, "[%s]", part)
+			continue
+		}
+
+		if b.Len() > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(part)
+	}
+
+	return b.String()
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
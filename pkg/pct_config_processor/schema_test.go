@@ -0,0 +1,41 @@
+package pct_config_processor // nolint
+
+import "testing"
+
+func TestValidateConfigVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    string
+	}{
+		{name: "full", version: `"1.0.0"`},
+		{name: "prerelease", version: `"1.0.0-rc.1"`},
+		{name: "number", version: `0.1`, want: `must be a semantic version such as "1.0.0". Versions such as 0.1 are no longer accepted, change it to "0.1.0"`},
+		{name: "short string", version: `"1.0"`, want: `must be a semantic version such as "1.0.0". Versions such as 1.0 are no longer accepted, change it to "1.0.0"`},
+		{name: "major only", version: `2`, want: `must be a semantic version such as "1.0.0". Versions such as 2 are no longer accepted, change it to "2.0.0"`},
+		{name: "invalid", version: `"latest"`, want: `must be a semantic version such as "1.0.0"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := "template:\n  id: example\n  author: test\n  version: " + tt.version + "\n"
+
+			problems := ValidateConfig("pct-config.yml", []byte(config))
+			if tt.want == "" {
+				if len(problems) != 0 {
+					t.Errorf("got problems %v, want none", problems)
+				}
+				return
+			}
+
+			if len(problems) != 1 {
+				t.Fatalf("got problems %v, want one", problems)
+			}
+
+			want := Problem{File: "pct-config.yml", Line: 4, Column: 12, Field: "template.version", Message: tt.want}
+			if problems[0] != want {
+				t.Errorf("got %+v, want %+v", problems[0], want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+// Package pdk_errors contains the catalogue of typed errors returned by the pdk.
+// Every error carries a stable code, the exit code that the cli should exit
+// with and a remediation hint that links to a 'pdk explain' topic.
+package pdk_errors // nolint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Kind describes a class of error.
+type Kind struct {
+	// Code is a stable identifier that can be relied on by scripts.
+	Code string `json:"code"`
+	// ExitCode is the code the cli exits with when an error of this kind
+	// is returned.
+	ExitCode int `json:"exit_code"`
+	// Topic is the 'pdk explain' topic that describes how to fix the error.
+	Topic string `json:"topic,omitempty"`
+	// Hint is a short remediation hint.
+	Hint string `json:"hint,omitempty"`
+}
+
+var (
+	Unknown = Kind{
+		Code:     "PDK000",
+		ExitCode: 1,
+		Hint:     "Run the command again with --debug for more details.",
+	}
+	Usage = Kind{
+		Code:     "PDK001",
+		ExitCode: 2,
+		Hint:     "Run the command again with --help to see the valid flags and arguments.",
+	}
+	Canceled = Kind{
+		Code:     "PDK002",
+		ExitCode: 130,
+		Hint:     "The operation was interrupted. Any temporary files have been removed.",
+	}
+	Timeout = Kind{
+		Code:     "PDK003",
+		ExitCode: 124,
+		Topic:    "config",
+		Hint:     "The operation took too long. Increase the timeout in the config file and try again.",
+	}
+	NotFound = Kind{
+		Code:     "PDK100",
+		ExitCode: 3,
+		Topic:    "install",
+		Hint:     "Check that the path or URL of the package is correct.",
+	}
+	AlreadyInstalled = Kind{
+		Code:     "PDK101",
+		ExitCode: 4,
+		Topic:    "install",
+		Hint:     "Use --force to replace the version that is already installed.",
+	}
+	InvalidPackage = Kind{
+		Code:     "PDK102",
+		ExitCode: 5,
+		Topic:    "install",
+		Hint:     "Check that the package is a tar.gz file created with 'pdk build'.",
+	}
+	InvalidTemplate = Kind{
+		Code:     "PDK200",
+		ExitCode: 6,
+		Topic:    "build",
+		Hint:     "Check pct-config.yml with 'pdk template lint' and make sure that a content directory exists.",
+	}
+	Hook = Kind{
+		Code:     "PDK201",
+		ExitCode: 15,
+		Topic:    "hooks",
+		Hint:     "Check the hooks section of pct-config.yml and that the commands that it runs are installed.",
+	}
+	MergeConflict = Kind{
+		Code:     "PDK202",
+		ExitCode: 16,
+		Topic:    "update",
+		Hint:     "Resolve the conflict markers in the listed files. Files that were merged cleanly are already updated.",
+	}
+	TemplateTest = Kind{
+		Code:     "PDK203",
+		ExitCode: 17,
+		Topic:    "authoring",
+		Hint:     "Check the reported differences. Run 'pdk template test --update' if the new output is correct.",
+	}
+	InvalidConfig = Kind{
+		Code:     "PDK300",
+		ExitCode: 7,
+		Topic:    "config",
+		Hint:     "Check the config file with 'pdk config edit'.",
+	}
+	ProfileNotFound = Kind{
+		Code:     "PDK301",
+		ExitCode: 8,
+		Topic:    "profiles",
+		Hint:     "List the available profiles with 'pdk config profile list'.",
+	}
+	Network = Kind{
+		Code:     "PDK400",
+		ExitCode: 9,
+		Topic:    "install",
+		Hint:     "Check your network connection and that the URL is reachable.",
+	}
+	Git = Kind{
+		Code:     "PDK401",
+		ExitCode: 10,
+		Topic:    "install",
+		Hint:     "Check that the repository URL and ref are correct and that you have access to the repository.",
+	}
+	Unauthorized = Kind{
+		Code:     "PDK402",
+		ExitCode: 12,
+		Topic:    "credentials",
+		Hint:     "Check the credentials configured for the host.",
+	}
+	TLS = Kind{
+		Code:     "PDK403",
+		ExitCode: 13,
+		Topic:    "network",
+		Hint:     "Check that the server certificate is trusted. Use 'pdk runtime status' to diagnose TLS problems.",
+	}
+	Offline = Kind{
+		Code:     "PDK404",
+		ExitCode: 14,
+		Topic:    "offline",
+		Hint:     "Install from a local file or run the command again without --offline.",
+	}
+	FileSystem = Kind{
+		Code:     "PDK500",
+		ExitCode: 11,
+		Hint:     "Check that the paths exist and that you have permission to write to them.",
+	}
+	Tool = Kind{
+		Code:     "PDK600",
+		ExitCode: 18,
+		Topic:    "tools",
+		Hint:     "Check the output of the tool above.",
+	}
+	ToolUnsupported = Kind{
+		Code:     "PDK601",
+		ExitCode: 19,
+		Topic:    "tools",
+		Hint:     "Check the capabilities and puppet versions of the tool with 'pdk exec --info', and the backend and puppet_version in the config.",
+	}
+)
+
+// Remediation returns the hint for the kind along with a pointer to the
+// relevant explain topic.
+func (k Kind) Remediation() string {
+	if k.Topic == "" {
+		return k.Hint
+	}
+
+	return fmt.Sprintf("%s See 'pdk explain %s' for more information.", k.Hint, k.Topic)
+}
+
+// Catalogue returns every kind of error in the order of their codes.
+func Catalogue() []Kind {
+	return []Kind{
+		Unknown,
+		Usage,
+		Canceled,
+		Timeout,
+		NotFound,
+		AlreadyInstalled,
+		InvalidPackage,
+		InvalidTemplate,
+		Hook,
+		MergeConflict,
+		TemplateTest,
+		InvalidConfig,
+		ProfileNotFound,
+		Network,
+		Git,
+		Unauthorized,
+		TLS,
+		Offline,
+		FileSystem,
+		Tool,
+		ToolUnsupported,
+	}
+}
+
+// Error is an error with a Kind.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+// New returns an Error of the given kind with a formatted message.
+func New(kind Kind, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns an Error of the given kind that wraps err. The formatted
+// message is prefixed to the message of err.
+func Wrap(kind Kind, err error, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the Kind of err. Cancelled and timed out contexts are
+// reported as Canceled and Timeout, otherwise the Kind of the first Error in
+// the chain of err is returned. Unknown is returned if there is none.
+func KindOf(err error) Kind {
+	if errors.Is(err, context.Canceled) {
+		return Canceled
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return Unknown
+}
@@ -0,0 +1,66 @@
+// Package project keeps track of the template that a project was generated
+// from, so that later versions of the template can be applied to it.
+package project
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+// StateFile is written to the root of every generated project.
+const StateFile = ".pdk-template.yml"
+
+// State records how a project was generated.
+type State struct {
+	// Template is the template in the form author/id.
+	Template string `yaml:"template"`
+	Version  string `yaml:"version"`
+
+	// Parameters are the values that the template was rendered with.
+	Parameters map[string]interface{} `yaml:"parameters,omitempty"`
+}
+
+// ReadState reads the state file in dir.
+func ReadState(afs *afero.Afero, dir string) (State, error) {
+	var state State
+
+	path := filepath.Join(dir, StateFile)
+	data, err := afs.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, pdk_errors.New(pdk_errors.NotFound, "%s does not have a %s file. Only projects created with 'pdk content new' can be updated", dir, StateFile)
+	}
+	if err != nil {
+		return state, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not read %s", path)
+	}
+
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return state, pdk_errors.Wrap(pdk_errors.InvalidConfig, err, "invalid %s", path)
+	}
+
+	if state.Template == "" || state.Version == "" {
+		return state, pdk_errors.New(pdk_errors.InvalidConfig, "%s must set template and version", path)
+	}
+
+	return state, nil
+}
+
+// WriteState writes state to the state file in dir.
+func WriteState(afs *afero.Afero, dir string, state State) error {
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	header := []byte("# Written by the pdk. It is used by 'pdk content update' to apply new versions of the template.\n")
+
+	path := filepath.Join(dir, StateFile)
+	if err := afs.WriteFile(path, append(header, data...), 0644); err != nil {
+		return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not write %s", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package project
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chelnak/pdk/pkg/merge"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/render"
+	"github.com/spf13/afero"
+)
+
+// The actions that an update can take on a file, in addition to
+// render.Unchanged and render.Conflict.
+const (
+	// Add writes a file that is new in the template.
+	Add render.Action = "add"
+
+	// Update replaces a file that was not changed in the project with the
+	// new version from the template.
+	Update render.Action = "update"
+
+	// Merged writes a file in which changes from the project and the
+	// template were combined without conflicts.
+	Merged render.Action = "merge"
+
+	// Delete removes a file that the template no longer has and that was
+	// not changed in the project.
+	Delete render.Action = "delete"
+
+	// Keep leaves a file alone because the project deleted it or changed a
+	// file that the template no longer has.
+	Keep render.Action = "keep"
+)
+
+// FileUpdate is what an update does to a single file.
+type FileUpdate struct {
+	Path   string
+	Action render.Action
+
+	// Content is written for Add, Update, Merged and Conflict. Binary
+	// files that conflict have no content and are left as they are.
+	Content []byte
+	Mode    os.FileMode
+	Binary  bool
+
+	// Current is the content of the file in the project, if it exists.
+	Current []byte
+
+	// Conflicts is the number of conflicting regions in Content.
+	Conflicts int
+}
+
+// PlanUpdate works out how to bring the changes between base, the project
+// rendered with the version of the template that it was generated from, and
+// next, the project rendered with the new version, in to the files in dir.
+func PlanUpdate(afs *afero.Afero, dir string, base, next []render.File, labels merge.Labels) ([]FileUpdate, error) {
+	baseFiles := index(base)
+	nextFiles := index(next)
+
+	paths := make([]string, 0, len(nextFiles))
+	for path := range nextFiles {
+		paths = append(paths, path)
+	}
+	for path := range baseFiles {
+		if _, ok := nextFiles[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	updates := make([]FileUpdate, 0, len(paths))
+	for _, path := range paths {
+		current, err := afs.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not read %s", path)
+		}
+		exists := err == nil
+
+		previous, inBase := baseFiles[path]
+		latest, inNext := nextFiles[path]
+
+		u := FileUpdate{Path: path, Action: render.Unchanged, Current: current}
+		if inNext {
+			u.Mode, u.Binary = latest.Mode, latest.Binary
+		} else {
+			u.Binary = previous.Binary
+		}
+
+		switch {
+		case !inNext:
+			// The template no longer has the file.
+			if exists && bytes.Equal(current, previous.Content) {
+				u.Action = Delete
+			} else if exists {
+				u.Action = Keep
+			}
+		case !inBase:
+			// The file is new in the template.
+			switch {
+			case !exists:
+				u.Action, u.Content = Add, latest.Content
+			case !bytes.Equal(current, latest.Content):
+				u = mergeFile(u, nil, current, latest.Content, labels)
+			}
+		case bytes.Equal(previous.Content, latest.Content):
+			// The template did not change the file.
+		case !exists:
+			u.Action = Keep
+		case bytes.Equal(current, previous.Content):
+			u.Action, u.Content = Update, latest.Content
+		case !bytes.Equal(current, latest.Content):
+			u = mergeFile(u, previous.Content, current, latest.Content, labels)
+		}
+
+		updates = append(updates, u)
+	}
+
+	return updates, nil
+}
+
+func mergeFile(u FileUpdate, base, current, next []byte, labels merge.Labels) FileUpdate {
+	if u.Binary {
+		u.Action = render.Conflict
+		return u
+	}
+
+	result := merge.Merge(base, current, next, labels)
+	u.Content, u.Conflicts = result.Content, result.Conflicts
+
+	u.Action = Merged
+	if result.Conflicts > 0 {
+		u.Action = render.Conflict
+	}
+
+	return u
+}
+
+// ApplyUpdate writes and deletes the files in dir according to updates.
+func ApplyUpdate(afs *afero.Afero, dir string, updates []FileUpdate) error {
+	for _, u := range updates {
+		path := filepath.Join(dir, filepath.FromSlash(u.Path))
+
+		switch {
+		case u.Action == Delete:
+			if err := afs.Remove(path); err != nil {
+				return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not delete %s", path)
+			}
+
+			removeEmptyParents(afs, dir, filepath.Dir(path))
+		case u.Action == Add, u.Action == Update, u.Action == Merged, u.Action == render.Conflict && !u.Binary:
+			if err := afs.MkdirAll(filepath.Dir(path), 0750); err != nil {
+				return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create %s", filepath.Dir(path))
+			}
+
+			if err := afs.WriteFile(path, u.Content, u.Mode); err != nil {
+				return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not write %s", path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeEmptyParents removes path and its parents up to, but not including,
+// root for as long as they are empty.
+func removeEmptyParents(afs *afero.Afero, root, path string) {
+	for path != root && strings.HasPrefix(path, root) {
+		if empty, err := afs.IsEmpty(path); err != nil || !empty {
+			return
+		}
+
+		if err := afs.Remove(path); err != nil {
+			return
+		}
+
+		path = filepath.Dir(path)
+	}
+}
+
+func index(files []render.File) map[string]render.File {
+	m := make(map[string]render.File, len(files))
+	for _, f := range files {
+		m[f.Path] = f
+	}
+
+	return m
+}
@@ -0,0 +1,241 @@
+package project
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chelnak/pdk/pkg/merge"
+	"github.com/chelnak/pdk/pkg/render"
+	"github.com/spf13/afero"
+)
+
+var labels = merge.Labels{Ours: "project", Base: "pdk/demo/0.1.0", Theirs: "pdk/demo/0.2.0"}
+
+// file describes a file in the base and next renders and in the project.
+// An empty string means that the file does not exist there.
+type file struct {
+	base, next, current string
+	binary              bool
+}
+
+// setup writes the current content of files to a memory file system and
+// returns it with the base and next renders.
+func setup(t *testing.T, files map[string]file) (*afero.Afero, []render.File, []render.File) {
+	t.Helper()
+
+	afs := &afero.Afero{Fs: afero.NewMemMapFs()}
+
+	var base, next []render.File
+	for path, f := range files {
+		if f.base != "" {
+			base = append(base, render.File{Path: path, Content: []byte(f.base), Mode: 0644, Binary: f.binary})
+		}
+		if f.next != "" {
+			next = append(next, render.File{Path: path, Content: []byte(f.next), Mode: 0644, Binary: f.binary})
+		}
+		if f.current != "" {
+			if err := afs.WriteFile("/project/"+path, []byte(f.current), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return afs, base, next
+}
+
+func TestPlanUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      file
+		action    render.Action
+		content   string
+		conflicts int
+	}{
+		{
+			name:   "unchanged everywhere",
+			file:   file{base: "a\n", next: "a\n", current: "a\n"},
+			action: render.Unchanged,
+		},
+		{
+			name:   "changed only in the project",
+			file:   file{base: "a\n", next: "a\n", current: "local\n"},
+			action: render.Unchanged,
+		},
+		{
+			name:    "new in the template",
+			file:    file{next: "new\n"},
+			action:  Add,
+			content: "new\n",
+		},
+		{
+			name:   "added on both sides with the same content",
+			file:   file{next: "new\n", current: "new\n"},
+			action: render.Unchanged,
+		},
+		{
+			name:      "added on both sides with different content",
+			file:      file{next: "theirs\n", current: "ours\n"},
+			action:    render.Conflict,
+			content:   "<<<<<<< project\nours\n||||||| pdk/demo/0.1.0\n=======\ntheirs\n>>>>>>> pdk/demo/0.2.0\n",
+			conflicts: 1,
+		},
+		{
+			name:    "changed only in the template",
+			file:    file{base: "v1\n", next: "v2\n", current: "v1\n"},
+			action:  Update,
+			content: "v2\n",
+		},
+		{
+			name:   "changed in the same way on both sides",
+			file:   file{base: "v1\n", next: "v2\n", current: "v2\n"},
+			action: render.Unchanged,
+		},
+		{
+			name:    "changed on both sides without overlapping",
+			file:    file{base: "a\nb\nc\n", next: "a\nb\nC\n", current: "A\nb\nc\n"},
+			action:  Merged,
+			content: "A\nb\nC\n",
+		},
+		{
+			name:      "changed on both sides in the same place",
+			file:      file{base: "a\nb\nc\n", next: "a\ntheirs\nc\n", current: "a\nours\nc\n"},
+			action:    render.Conflict,
+			content:   "a\n<<<<<<< project\nours\n||||||| pdk/demo/0.1.0\nb\n=======\ntheirs\n>>>>>>> pdk/demo/0.2.0\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:   "deleted in the project and changed in the template",
+			file:   file{base: "v1\n", next: "v2\n"},
+			action: Keep,
+		},
+		{
+			name:   "deleted in the template",
+			file:   file{base: "v1\n", current: "v1\n"},
+			action: Delete,
+		},
+		{
+			name:   "deleted in the template and edited in the project",
+			file:   file{base: "v1\n", current: "local\n"},
+			action: Keep,
+		},
+		{
+			name:   "deleted on both sides",
+			file:   file{base: "v1\n"},
+			action: render.Unchanged,
+		},
+		{
+			name:   "binary file changed on both sides",
+			file:   file{base: "\x00v1", next: "\x00v2", current: "\x00local", binary: true},
+			action: render.Conflict,
+		},
+		{
+			name:    "binary file changed only in the template",
+			file:    file{base: "\x00v1", next: "\x00v2", current: "\x00v1", binary: true},
+			action:  Update,
+			content: "\x00v2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			afs, base, next := setup(t, map[string]file{"dir/file": tt.file})
+
+			updates, err := PlanUpdate(afs, "/project", base, next, labels)
+			if err != nil {
+				t.Fatalf("PlanUpdate() error = %v", err)
+			}
+
+			if len(updates) != 1 {
+				t.Fatalf("got %d updates, want 1", len(updates))
+			}
+
+			u := updates[0]
+			if u.Path != "dir/file" || u.Action != tt.action {
+				t.Errorf("got %s %s, want %s dir/file", u.Action, u.Path, tt.action)
+			}
+			if string(u.Content) != tt.content {
+				t.Errorf("got content %q, want %q", u.Content, tt.content)
+			}
+			if u.Conflicts != tt.conflicts {
+				t.Errorf("got %d conflicts, want %d", u.Conflicts, tt.conflicts)
+			}
+			if string(u.Current) != tt.file.current {
+				t.Errorf("got current %q, want %q", u.Current, tt.file.current)
+			}
+			if u.Binary != tt.file.binary {
+				t.Errorf("got binary %t, want %t", u.Binary, tt.file.binary)
+			}
+		})
+	}
+}
+
+func TestPlanUpdateOrder(t *testing.T) {
+	afs, base, next := setup(t, map[string]file{
+		"b":     {base: "b\n", next: "b\n", current: "b\n"},
+		"a/new": {next: "new\n"},
+		"c/old": {base: "old\n", current: "old\n"},
+	})
+
+	updates, err := PlanUpdate(afs, "/project", base, next, labels)
+	if err != nil {
+		t.Fatalf("PlanUpdate() error = %v", err)
+	}
+
+	var got []string
+	for _, u := range updates {
+		got = append(got, string(u.Action)+" "+u.Path)
+	}
+
+	want := []string{"add a/new", "unchanged b", "delete c/old"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestApplyUpdate(t *testing.T) {
+	afs, base, next := setup(t, map[string]file{
+		"new/file":     {next: "new\n"},
+		"update":       {base: "v1\n", next: "v2\n", current: "v1\n"},
+		"conflict":     {base: "a\n", next: "theirs\n", current: "ours\n"},
+		"image.png":    {base: "\x00v1", next: "\x00v2", current: "\x00local", binary: true},
+		"old/dir/file": {base: "old\n", current: "old\n"},
+		"kept":         {base: "old\n", current: "local\n"},
+	})
+
+	updates, err := PlanUpdate(afs, "/project", base, next, labels)
+	if err != nil {
+		t.Fatalf("PlanUpdate() error = %v", err)
+	}
+
+	if err := ApplyUpdate(afs, "/project", updates); err != nil {
+		t.Fatalf("ApplyUpdate() error = %v", err)
+	}
+
+	want := map[string]string{
+		"new/file":  "new\n",
+		"update":    "v2\n",
+		"image.png": "\x00local",
+		"kept":      "local\n",
+	}
+	for path, content := range want {
+		got, err := afs.ReadFile("/project/" + path)
+		if err != nil || string(got) != content {
+			t.Errorf("got %s %q (%v), want %q", path, got, err, content)
+		}
+	}
+
+	conflict, err := afs.ReadFile("/project/conflict")
+	if err != nil || !strings.HasPrefix(string(conflict), "<<<<<<< project\n") {
+		t.Errorf("got conflict %q (%v), want conflict markers", conflict, err)
+	}
+
+	if _, err := afs.Stat("/project/old"); !os.IsNotExist(err) {
+		t.Errorf("got error %v for /project/old, want the empty directories to be removed", err)
+	}
+
+	if _, err := afs.Stat("/project"); err != nil {
+		t.Errorf("/project was removed: %v", err)
+	}
+}
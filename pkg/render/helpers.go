@@ -0,0 +1,203 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/chelnak/pdk/internal/stringutils"
+)
+
+var (
+	classNamePattern = regexp.MustCompile(`^(::)?[a-z][a-z0-9_]*(::[a-z][a-z0-9_]*)*$`)
+
+	// reservedWords cannot be used as class names.
+	// https://puppet.com/docs/puppet/latest/lang_reserved.html
+	reservedWords = map[string]bool{
+		"and": true, "application": true, "attr": true, "case": true, "class": true, "component": true, "consumes": true,
+		"default": true, "define": true, "else": true, "elsif": true, "environment": true, "false": true, "function": true,
+		"if": true, "import": true, "in": true, "inherits": true, "main": true, "node": true, "or": true,
+		"private": true, "produces": true, "regexp": true, "settings": true, "site": true, "true": true,
+		"type": true, "undef": true, "unit": true, "unless": true,
+	}
+)
+
+// Helpers returns the functions that templates can use in addition to the
+// text/template builtins.
+func Helpers() template.FuncMap {
+	return template.FuncMap{
+		// Case conversion.
+		"lower":  strings.ToLower,
+		"upper":  strings.ToUpper,
+		"title":  Title,
+		"snake":  Snake,
+		"kebab":  Kebab,
+		"camel":  Camel,
+		"pascal": Pascal,
+
+		// Puppet.
+		"isClassName": IsClassName,
+		"className":   ClassName,
+
+		// Pluralisation.
+		"plural":    stringutils.Plural,
+		"pluralize": stringutils.Pluralize,
+
+		// Strings.
+		"trim":    strings.TrimSpace,
+		"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"join":    join,
+		"quote":   func(v interface{}) string { return fmt.Sprintf("%q", fmt.Sprint(v)) },
+		"indent":  indent,
+		"default": defaultValue,
+	}
+}
+
+// words splits s in to lower case words at spaces, punctuation and changes
+// from lower to upper case, so that "fooBar", "foo_bar" and "Foo Bar" all
+// give foo and bar.
+func words(s string) []string {
+	var result []string
+	var current []rune
+
+	flush := func() {
+		if len(current) > 0 {
+			result = append(result, strings.ToLower(string(current)))
+			current = current[:0]
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// Split fooBar before B and HTTPServer before S.
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+
+		current = append(current, r)
+	}
+	flush()
+
+	return result
+}
+
+// Title returns s as words separated by spaces, each starting with a capital
+// letter.
+func Title(s string) string {
+	w := words(s)
+	for i := range w {
+		w[i] = stringutils.Capitalise(w[i])
+	}
+
+	return strings.Join(w, " ")
+}
+
+// Snake returns s in snake_case.
+func Snake(s string) string {
+	return strings.Join(words(s), "_")
+}
+
+// Kebab returns s in kebab-case.
+func Kebab(s string) string {
+	return strings.Join(words(s), "-")
+}
+
+// Camel returns s in camelCase.
+func Camel(s string) string {
+	w := words(s)
+	for i := 1; i < len(w); i++ {
+		w[i] = stringutils.Capitalise(w[i])
+	}
+
+	return strings.Join(w, "")
+}
+
+// Pascal returns s in PascalCase.
+func Pascal(s string) string {
+	return stringutils.Capitalise(Camel(s))
+}
+
+// IsClassName returns true if s is a valid Puppet class name, such as
+// apache or apache::mod::ssl.
+func IsClassName(s string) bool {
+	if !classNamePattern.MatchString(s) {
+		return false
+	}
+
+	for _, segment := range strings.Split(strings.TrimPrefix(s, "::"), "::") {
+		if reservedWords[segment] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ClassName returns s unchanged if it is a valid Puppet class name and fails
+// the render otherwise.
+func ClassName(s string) (string, error) {
+	if !IsClassName(s) {
+		return "", fmt.Errorf("%q is not a valid Puppet class name", s)
+	}
+
+	return s, nil
+}
+
+func join(sep string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case []string:
+		return strings.Join(v, sep), nil
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, sep), nil
+	}
+
+	return "", fmt.Errorf("join expects a list but got %v", v)
+}
+
+// indent adds n spaces to the start of every line of s that is not empty.
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// defaultValue returns fallback when v is empty, so that templates can write
+// {{.owner | default "puppet"}}. Templates are rendered with missingkey=error,
+// so a key that may be missing from the data altogether is read with index:
+// {{index . "owner" | default "puppet"}}.
+func defaultValue(fallback, v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return fallback
+	case string:
+		if v == "" {
+			return fallback
+		}
+	case []string:
+		if len(v) == 0 {
+			return fallback
+		}
+	}
+
+	return v
+}
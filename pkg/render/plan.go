@@ -0,0 +1,207 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Action is what happens to a rendered file when it is written.
+type Action string
+
+const (
+	// Create writes a file that does not exist yet.
+	Create Action = "create"
+
+	// Unchanged leaves a file that already has the rendered content.
+	Unchanged Action = "unchanged"
+
+	// Conflict marks a file that exists with different content. Conflicts
+	// must be resolved to Overwrite or Skip before they are written.
+	Conflict Action = "conflict"
+
+	// Overwrite replaces an existing file.
+	Overwrite Action = "overwrite"
+
+	// Skip leaves an existing file as it is.
+	Skip Action = "skip"
+)
+
+// ConflictPolicy decides what happens to files that already exist with
+// different content.
+type ConflictPolicy string
+
+const (
+	ConflictFail      ConflictPolicy = "fail"
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictPrompt    ConflictPolicy = "prompt"
+)
+
+// ConflictPolicies returns the names of the supported policies.
+func ConflictPolicies() []string {
+	return []string{string(ConflictSkip), string(ConflictOverwrite), string(ConflictPrompt), string(ConflictFail)}
+}
+
+// Change is a rendered file and what writing it to the target will do.
+type Change struct {
+	File
+	Action Action
+
+	// Existing is the content of the file in the target, if there is one.
+	Existing []byte
+}
+
+// Plan compares files with the contents of target and returns the change
+// that writing each one would make.
+func (r *renderer) Plan(target string, files []File) ([]Change, error) {
+	changes := make([]Change, 0, len(files))
+
+	for _, f := range files {
+		dest := filepath.Join(target, filepath.FromSlash(f.Path))
+		change := Change{File: f, Action: Create}
+
+		info, err := r.AFS.Stat(dest)
+		if err == nil {
+			if info.IsDir() {
+				return nil, pdk_errors.New(pdk_errors.FileSystem, "%s is a directory", dest)
+			}
+
+			change.Existing, err = r.AFS.ReadFile(dest)
+			if err != nil {
+				return nil, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not read %s", dest)
+			}
+
+			change.Action = Conflict
+			if bytes.Equal(change.Existing, f.Content) {
+				change.Action = Unchanged
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// Resolve turns every conflict in changes in to an Overwrite or a Skip
+// according to policy. confirm is asked about each conflict when policy is
+// ConflictPrompt and returns true to overwrite the file. An error listing
+// the conflicts is returned when policy is ConflictFail.
+func Resolve(changes []Change, policy ConflictPolicy, confirm func(Change) (bool, error)) error {
+	var conflicts []string
+
+	for i := range changes {
+		if changes[i].Action != Conflict {
+			continue
+		}
+
+		switch policy {
+		case ConflictSkip:
+			changes[i].Action = Skip
+		case ConflictOverwrite:
+			changes[i].Action = Overwrite
+		case ConflictPrompt:
+			overwrite, err := confirm(changes[i])
+			if err != nil {
+				return err
+			}
+
+			changes[i].Action = Skip
+			if overwrite {
+				changes[i].Action = Overwrite
+			}
+		case ConflictFail, "":
+			conflicts = append(conflicts, changes[i].Path)
+		default:
+			return pdk_errors.New(pdk_errors.Usage, "unknown conflict policy %q. Use one of %s", policy, strings.Join(ConflictPolicies(), ", "))
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return pdk_errors.New(pdk_errors.FileSystem, "%d files already exist with different content: %s", len(conflicts), strings.Join(conflicts, ", "))
+	}
+
+	return nil
+}
+
+// Diff returns a unified diff from the existing content of the file to the
+// rendered content. It is empty for new and binary files.
+func Diff(change Change) (string, error) {
+	if change.Existing == nil || change.Binary || bytes.Equal(change.Existing, change.Content) {
+		return "", nil
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(change.Existing)),
+		B:        difflib.SplitLines(string(change.Content)),
+		FromFile: change.Path + " (existing)",
+		ToFile:   change.Path + " (template)",
+		Context:  3,
+	})
+}
+
+// Tree draws the paths of changes as a tree under root, with the action
+// for each file.
+func Tree(root string, changes []Change) string {
+	type node struct {
+		children map[string]*node
+		action   Action
+		binary   bool
+	}
+
+	top := &node{children: map[string]*node{}}
+	for _, c := range changes {
+		n := top
+		for _, part := range strings.Split(c.Path, "/") {
+			child, ok := n.children[part]
+			if !ok {
+				child = &node{children: map[string]*node{}}
+				n.children[part] = child
+			}
+			n = child
+		}
+
+		n.action = c.Action
+		n.binary = c.Binary
+	}
+
+	var b strings.Builder
+	b.WriteString(root + "\n")
+
+	var walk func(n *node, prefix string)
+	walk = func(n *node, prefix string) {
+		names := make([]string, 0, len(n.children))
+		for name := range n.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for i, name := range names {
+			child := n.children[name]
+			branch, indent := "├── ", "│   "
+			if i == len(names)-1 {
+				branch, indent = "└── ", "    "
+			}
+
+			label := name
+			if child.action != "" {
+				label = fmt.Sprintf("%s (%s)", name, child.action)
+				if child.binary {
+					label = fmt.Sprintf("%s (%s, binary)", name, child.action)
+				}
+			}
+
+			b.WriteString(prefix + branch + label + "\n")
+			walk(child, prefix+indent)
+		}
+	}
+	walk(top, "")
+
+	return strings.TrimRight(b.String(), "\n")
+}
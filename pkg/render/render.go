@@ -0,0 +1,326 @@
+// Package render turns the content directory of a template in to files.
+// File and directory names and the contents of text files are executed as Go
+// templates. Binary files are copied as they are.
+package render
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+const (
+	// ContentDir is the directory of a template that holds its content.
+	ContentDir = "content"
+
+	// PartialsDir is the directory of a template that holds partials. Each
+	// file in it can be used from content with {{template "name" .}} or
+	// {{include "name" .}}, where name is its path relative to PartialsDir
+	// without the .tmpl suffix.
+	PartialsDir = "partials"
+
+	// TemplateSuffix is removed from the names of rendered files. It lets a
+	// template hold files such as Gemfile.tmpl without other tools treating
+	// them as the real thing.
+	TemplateSuffix = ".tmpl"
+
+	// sniffLength is how much of a file is looked at to decide whether it
+	// is binary.
+	sniffLength = 8000
+)
+
+// File is a single rendered file.
+type File struct {
+	// Source is the path of the file in the template.
+	Source string
+
+	// Path is the rendered path of the file relative to the target
+	// directory.
+	Path string
+
+	Content []byte
+	Mode    os.FileMode
+
+	// Binary is true for files that were copied without being rendered.
+	Binary bool
+}
+
+// Renderer renders templates.
+type Renderer interface {
+	// Render renders the content directory of the template in root with
+	// data and returns the files in path order. Nothing is written.
+	Render(root string, data map[string]interface{}) ([]File, error)
+
+	// Plan compares files with the contents of target and returns the
+	// change that writing each one would make.
+	Plan(target string, files []File) ([]Change, error)
+
+	// Write applies changes to target and returns the paths that it wrote.
+	// Only Create and Overwrite changes are written. It fails without
+	// writing anything if a conflict has not been resolved or if a file
+	// that is to be created already exists.
+	Write(target string, changes []Change) ([]string, error)
+}
+
+type renderer struct {
+	AFS *afero.Afero
+}
+
+func (r *renderer) Render(root string, data map[string]interface{}) ([]File, error) {
+	base, err := r.partials(filepath.Join(root, PartialsDir))
+	if err != nil {
+		return nil, err
+	}
+
+	source := filepath.Join(root, ContentDir)
+	var files []File
+
+	err = r.AFS.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == source {
+			return nil
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+
+		target, err := renderPath(base, rel, data)
+		if err != nil {
+			return err
+		}
+
+		if target == "" {
+			log.Debug().Str("path", rel).Msg("skipping path with an empty name")
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := r.renderFile(base, path, rel, data)
+		if err != nil {
+			return err
+		}
+
+		file.Path = strings.TrimSuffix(target, TemplateSuffix)
+		file.Mode = info.Mode().Perm()
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	for i := 1; i < len(files); i++ {
+		if files[i].Path == files[i-1].Path {
+			return nil, pdk_errors.New(pdk_errors.InvalidTemplate, "%s and %s both render to %s", files[i-1].Source, files[i].Source, files[i].Path)
+		}
+	}
+
+	return files, nil
+}
+
+// partials parses every file in dir in to a template set that content is
+// rendered with.
+func (r *renderer) partials(dir string) (*template.Template, error) {
+	// include is replaced by renderFile and renderPath with one that can see
+	// the template being rendered. It is declared here so that partials can
+	// use it too.
+	base := template.New("").Option("missingkey=error").Funcs(Helpers()).Funcs(template.FuncMap{
+		"include": func(string, interface{}) (string, error) { return "", nil },
+	})
+
+	if ok, _ := r.AFS.DirExists(dir); !ok {
+		return base, nil
+	}
+
+	err := r.AFS.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := r.AFS.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(filepath.ToSlash(rel), TemplateSuffix)
+		log.Debug().Str("name", name).Msg("parsing partial")
+		if _, err := base.New(name).Parse(string(content)); err != nil {
+			return pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "invalid partial %s", rel)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return base, nil
+}
+
+// renderFile renders a single file. path is where it is on disk and rel is
+// its path relative to the content directory.
+func (r *renderer) renderFile(base *template.Template, path, rel string, data map[string]interface{}) (File, error) {
+	file := File{Source: filepath.ToSlash(rel)}
+
+	content, err := r.AFS.ReadFile(path)
+	if err != nil {
+		return file, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not read %s", path)
+	}
+
+	if isBinary(content) {
+		file.Content = content
+		file.Binary = true
+		return file, nil
+	}
+
+	tmpl, err := base.Clone()
+	if err != nil {
+		return file, err
+	}
+
+	tmpl = tmpl.Funcs(template.FuncMap{"include": include(tmpl)})
+	if _, err := tmpl.New(file.Source).Parse(string(content)); err != nil {
+		return file, pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "invalid template %s", file.Source)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&out, file.Source, data); err != nil {
+		return file, pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "could not render %s", file.Source)
+	}
+
+	file.Content = out.Bytes()
+	return file, nil
+}
+
+func (r *renderer) Write(target string, changes []Change) ([]string, error) {
+	for _, c := range changes {
+		dest := filepath.Join(target, filepath.FromSlash(c.Path))
+		switch c.Action {
+		case Conflict:
+			return nil, pdk_errors.New(pdk_errors.FileSystem, "%s already exists", dest)
+		case Create:
+			if ok, _ := r.AFS.Exists(dest); ok {
+				return nil, pdk_errors.New(pdk_errors.FileSystem, "%s already exists", dest)
+			}
+		}
+	}
+
+	var written []string
+	for _, c := range changes {
+		if c.Action != Create && c.Action != Overwrite {
+			continue
+		}
+
+		dest := filepath.Join(target, filepath.FromSlash(c.Path))
+		if err := r.AFS.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+			return written, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create %s", filepath.Dir(dest))
+		}
+
+		if err := r.AFS.WriteFile(dest, c.Content, c.Mode); err != nil {
+			return written, pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not write %s", dest)
+		}
+
+		written = append(written, dest)
+	}
+
+	return written, nil
+}
+
+// renderPath renders each element of rel. It returns an empty path when any
+// element renders to an empty string, so that templates can leave out files
+// and directories with names such as {{if .tests}}spec{{end}}.
+func renderPath(base *template.Template, rel string, data map[string]interface{}) (string, error) {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+
+	for i, part := range parts {
+		if !strings.Contains(part, "{{") {
+			continue
+		}
+
+		tmpl, err := base.Clone()
+		if err != nil {
+			return "", err
+		}
+
+		tmpl = tmpl.Funcs(template.FuncMap{"include": include(tmpl)})
+		if _, err := tmpl.New(rel).Parse(part); err != nil {
+			return "", pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "invalid path %s", rel)
+		}
+
+		var out bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&out, rel, data); err != nil {
+			return "", pdk_errors.Wrap(pdk_errors.InvalidTemplate, err, "could not render path %s", rel)
+		}
+
+		name := strings.TrimSpace(out.String())
+		if name == "" {
+			return "", nil
+		}
+
+		if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return "", pdk_errors.New(pdk_errors.InvalidTemplate, "path %s renders to %q, which is not a valid name", rel, name)
+		}
+
+		parts[i] = name
+	}
+
+	return strings.Join(parts, "/"), nil
+}
+
+// include returns a helper that executes the named template and returns the
+// result, so that it can be piped in to other helpers.
+func include(tmpl *template.Template) func(string, interface{}) (string, error) {
+	return func(name string, data interface{}) (string, error) {
+		var out bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&out, name, data); err != nil {
+			return "", err
+		}
+
+		return out.String(), nil
+	}
+}
+
+// isBinary returns true if content does not look like text.
+func isBinary(content []byte) bool {
+	sniff := content
+	if len(sniff) > sniffLength {
+		sniff = sniff[:sniffLength]
+		// Do not count a character cut in half at the end as invalid.
+		for i := 0; i < utf8.UTFMax-1 && !utf8.Valid(sniff); i++ {
+			sniff = sniff[:len(sniff)-1]
+		}
+	}
+
+	return bytes.IndexByte(sniff, 0) != -1 || !utf8.Valid(sniff)
+}
+
+// NewRenderer returns a Renderer that works on the local file system.
+func NewRenderer() Renderer {
+	return &renderer{AFS: &afero.Afero{Fs: afero.NewOsFs()}}
+}
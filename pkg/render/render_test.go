@@ -0,0 +1,223 @@
+package render
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/spf13/afero"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// readData reads the template data for a test case from data.json.
+func readData(t *testing.T, dir string) map[string]interface{} {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join(dir, "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// readTree returns the contents of every file under dir by slash separated
+// path.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		files[filepath.ToSlash(rel)] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+// TestRenderGolden renders testdata/<case>/template with
+// testdata/<case>/data.json and compares the result with the files in
+// testdata/<case>/want.
+func TestRenderGolden(t *testing.T) {
+	for _, name := range []string{"helpers", "missing", "partials", "paths"} {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join("testdata", name)
+
+			files, err := NewRenderer().Render(filepath.Join(dir, "template"), readData(t, dir))
+			if err != nil {
+				t.Fatalf("Render() returned an error: %v", err)
+			}
+
+			got := map[string]string{}
+			for _, f := range files {
+				got[f.Path] = string(f.Content)
+			}
+
+			want := readTree(t, filepath.Join(dir, "want"))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got\n%q\nwant\n%q", got, want)
+			}
+		})
+	}
+}
+
+// TestPlanGolden plans testdata/plan/template against testdata/plan/target
+// and compares the tree and diffs with testdata/plan/plan.golden. Run the
+// tests with -update to rewrite it.
+func TestPlanGolden(t *testing.T) {
+	dir := filepath.Join("testdata", "plan")
+	r := NewRenderer()
+
+	files, err := r.Render(filepath.Join(dir, "template"), readData(t, dir))
+	if err != nil {
+		t.Fatalf("Render() returned an error: %v", err)
+	}
+
+	changes, err := r.Plan(filepath.Join(dir, "target"), files)
+	if err != nil {
+		t.Fatalf("Plan() returned an error: %v", err)
+	}
+
+	var b strings.Builder
+	b.WriteString(Tree("target", changes) + "\n")
+	for _, c := range changes {
+		diff, err := Diff(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff != "" {
+			b.WriteString("\n" + diff)
+		}
+	}
+
+	golden := filepath.Join(dir, "plan.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(b.String()), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := b.String(); got != string(want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	plan := func() []Change {
+		return []Change{
+			{File: File{Path: "new"}, Action: Create},
+			{File: File{Path: "same"}, Action: Unchanged},
+			{File: File{Path: "a"}, Action: Conflict},
+			{File: File{Path: "b"}, Action: Conflict},
+		}
+	}
+
+	actions := func(changes []Change) []Action {
+		result := make([]Action, 0, len(changes))
+		for _, c := range changes {
+			result = append(result, c.Action)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		policy ConflictPolicy
+		want   []Action
+	}{
+		{name: "skip", policy: ConflictSkip, want: []Action{Create, Unchanged, Skip, Skip}},
+		{name: "overwrite", policy: ConflictOverwrite, want: []Action{Create, Unchanged, Overwrite, Overwrite}},
+		{name: "prompt", policy: ConflictPrompt, want: []Action{Create, Unchanged, Overwrite, Skip}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := plan()
+			err := Resolve(changes, tt.policy, func(c Change) (bool, error) { return c.Path == "a", nil })
+			if err != nil {
+				t.Fatalf("Resolve() returned an error: %v", err)
+			}
+
+			if got := actions(changes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		err := Resolve(plan(), ConflictFail, nil)
+		if kind := pdk_errors.KindOf(err); kind != pdk_errors.FileSystem {
+			t.Fatalf("got kind %s, want %s: %v", kind.Code, pdk_errors.FileSystem.Code, err)
+		}
+
+		if !strings.Contains(err.Error(), "2 files already exist with different content: a, b") {
+			t.Errorf("error %q does not list the conflicts", err)
+		}
+	})
+}
+
+// Paths with quotes cannot be checked in, so include in paths is tested
+// with an in memory file system.
+func TestRenderPathInclude(t *testing.T) {
+	afs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	files := map[string]string{
+		"/template/partials/filename.tmpl":                 "{{snake .name}}",
+		"/template/content/{{include \"filename\" .}}.pp":  "class example {}\n",
+		"/template/content/{{include \"missing\" .}}/skip": "",
+	}
+
+	r := &renderer{AFS: afs}
+	for path, content := range files {
+		if err := afs.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := r.Render("/template", map[string]interface{}{"name": "webServer"})
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.InvalidTemplate {
+		t.Fatalf("got kind %s, want %s: %v", kind.Code, pdk_errors.InvalidTemplate.Code, err)
+	}
+
+	if err := afs.RemoveAll("/template/content/{{include \"missing\" .}}"); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := r.Render("/template", map[string]interface{}{"name": "webServer"})
+	if err != nil {
+		t.Fatalf("Render() returned an error: %v", err)
+	}
+
+	if len(rendered) != 1 || rendered[0].Path != "web_server.pp" {
+		t.Errorf("got %+v, want web_server.pp", rendered)
+	}
+}
@@ -0,0 +1,2 @@
+class web_server {
+}
@@ -0,0 +1 @@
+require 'rspec-puppet'
@@ -0,0 +1,98 @@
+// Package scaffold creates new template projects for 'pdk template init'.
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"path/filepath"
+	"text/template"
+
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/chelnak/pdk/pkg/render"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+)
+
+// files is the skeleton of a template project. The contents of each file
+// are rendered with Options using [[ and ]] as delimiters, so that the
+// {{ and }} of the generated template are left alone. Names are copied as
+// they are.
+//
+//go:embed all:files
+var files embed.FS
+
+const root = "files"
+
+// Options describe the template that is created.
+type Options struct {
+	ID      string
+	Author  string
+	Display string
+}
+
+// Scaffolder creates template projects.
+type Scaffolder interface {
+	// Create writes a new template project to dir and returns the paths
+	// that it wrote. It fails without writing anything if dir already holds
+	// a pct-config.yml.
+	Create(dir string, opts Options) ([]string, error)
+}
+
+type scaffolder struct {
+	AFS *afero.Afero
+}
+
+func (s *scaffolder) Create(dir string, opts Options) ([]string, error) {
+	config := filepath.Join(dir, "pct-config.yml")
+	if ok, _ := s.AFS.Exists(config); ok {
+		return nil, pdk_errors.New(pdk_errors.FileSystem, "%s already exists", config)
+	}
+
+	var written []string
+	err := fs.WalkDir(files, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		content, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		tmpl, err := template.New(name).Delims("[[", "]]").Funcs(render.Helpers()).Parse(string(content))
+		if err != nil {
+			return err
+		}
+
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, opts); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, filepath.FromSlash(name))
+		if err != nil {
+			return err
+		}
+
+		dest := filepath.Join(dir, rel)
+		log.Debug().Str("path", dest).Msg("writing scaffold file")
+		if err := s.AFS.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+			return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not create %s", filepath.Dir(dest))
+		}
+
+		if err := s.AFS.WriteFile(dest, out.Bytes(), 0644); err != nil {
+			return pdk_errors.Wrap(pdk_errors.FileSystem, err, "could not write %s", dest)
+		}
+
+		written = append(written, dest)
+		return nil
+	})
+
+	return written, err
+}
+
+// NewScaffolder returns a Scaffolder that works on the local file system.
+func NewScaffolder() Scaffolder {
+	return &scaffolder{AFS: &afero.Afero{Fs: afero.NewOsFs()}}
+}
@@ -0,0 +1,131 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OutputFormat is how a tool reports its results.
+type OutputFormat string
+
+const (
+	// Text output is matched line by line against a pattern.
+	Text OutputFormat = "text"
+
+	// JSON output is an array of objects with the fields of Result.
+	JSON OutputFormat = "json"
+)
+
+// Output says how to turn the output of a tool in to results. Tools without
+// an output format only have their output shown as it is.
+type Output struct {
+	Format OutputFormat `yaml:"format"`
+
+	// Pattern is a regular expression that text output is matched against
+	// line by line. Its named groups file, line, column, severity, rule and
+	// message fill in the fields of a Result. Lines that do not match are
+	// ignored.
+	Pattern string `yaml:"pattern"`
+
+	// Stream is stdout or stderr. It defaults to stdout.
+	Stream string `yaml:"stream"`
+}
+
+// Result is a single finding reported by a tool.
+type Result struct {
+	Tool     string `json:"tool,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Location returns the file, line and column of the result in the form
+// file:line:column, leaving out the parts that are not known.
+func (r Result) Location() string {
+	location := r.File
+	if r.Line > 0 {
+		location += ":" + strconv.Itoa(r.Line)
+		if r.Column > 0 {
+			location += ":" + strconv.Itoa(r.Column)
+		}
+	}
+
+	return location
+}
+
+// Parses returns true if results can be parsed from the output.
+func (o Output) Parses() bool {
+	return o.Format != ""
+}
+
+// Parse turns the stdout and stderr of a tool in to results.
+func (o Output) Parse(stdout, stderr []byte) ([]Result, error) {
+	data := stdout
+	if o.Stream == "stderr" {
+		data = stderr
+	}
+
+	switch o.Format {
+	case "":
+		return nil, nil
+	case JSON:
+		var results []Result
+		if err := json.Unmarshal(data, &results); err != nil {
+			return nil, fmt.Errorf("could not parse the output as json: %w", err)
+		}
+
+		return results, nil
+	case Text:
+		return o.parseText(data)
+	default:
+		return nil, fmt.Errorf("unknown output format %q", o.Format)
+	}
+}
+
+func (o Output) parseText(data []byte) ([]Result, error) {
+	pattern, err := regexp.Compile(o.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output pattern: %w", err)
+	}
+
+	var results []Result
+	for _, line := range strings.Split(string(data), "\n") {
+		match := pattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil {
+			continue
+		}
+
+		var r Result
+		for i, name := range pattern.SubexpNames() {
+			value := match[i]
+			switch name {
+			case "file":
+				r.File = value
+			case "line":
+				r.Line, _ = strconv.Atoi(value)
+			case "column":
+				r.Column, _ = strconv.Atoi(value)
+			case "severity":
+				r.Severity = strings.ToLower(value)
+			case "rule":
+				r.Rule = value
+			case "message":
+				r.Message = value
+			}
+		}
+
+		if r.Message == "" {
+			r.Message = strings.TrimSpace(line)
+		}
+
+		results = append(results, r)
+	}
+
+	return results, nil
+}
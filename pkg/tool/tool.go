@@ -0,0 +1,321 @@
+// Package tool runs tool packages. A tool is a package whose pct-config.yml
+// has the type tool and a tool section that says how to run it. Tools are
+// run against Puppet content by 'pdk exec' and 'pdk validate'.
+package tool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/exec_runner"
+	"github.com/chelnak/pdk/pkg/network"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DirEnv is the environment variable that holds the directory of the
+	// tool package while it runs.
+	DirEnv = "PDK_TOOL_DIR"
+
+	// PuppetVersionEnv is the environment variable that holds the
+	// configured puppet version while a tool runs.
+	PuppetVersionEnv = "PDK_PUPPET_VERSION"
+
+	// Docker and Local are the backends that tools can run with. Tools with
+	// an image need Docker. Tools with a binary always run locally.
+	Docker = "docker"
+	Local  = "local"
+
+	// codeMount and toolMount are where the content and the tool package
+	// are mounted in the container of an image tool.
+	codeMount = "/code"
+	toolMount = "/tool"
+
+	// dockerRunError is the exit code of docker run when the container could
+	// not be started, such as when the image is missing.
+	dockerRunError = 125
+)
+
+// Capability is something that a tool can do.
+type Capability string
+
+const (
+	Validate Capability = "validate"
+	Test     Capability = "test"
+	Format   Capability = "format"
+)
+
+// Capabilities returns the names of every capability.
+func Capabilities() []string {
+	return []string{string(Validate), string(Test), string(Format)}
+}
+
+// Invocation is how a tool provides a capability.
+type Invocation struct {
+	// Args are passed to the tool after the args of its Spec.
+	Args []string `yaml:"args"`
+}
+
+// Spec is the tool section of a pct-config.yml.
+type Spec struct {
+	// Binary is the command that runs the tool locally. A path such as
+	// bin/lint is relative to the package. A bare name is looked up on
+	// the PATH.
+	Binary string `yaml:"binary"`
+
+	// Image is the container image that the tool runs in. It is used
+	// instead of Binary and needs the docker backend.
+	Image string `yaml:"image"`
+
+	// Entrypoint replaces the entrypoint of Image. A path such as bin/lint
+	// is relative to the package, which is mounted in the container.
+	Entrypoint string `yaml:"entrypoint"`
+
+	// Args are passed to the tool before any other arguments.
+	Args []string `yaml:"args"`
+
+	// PuppetVersions are the versions of puppet that the tool supports. A
+	// version such as 7 or 7.14 matches every release that it is a prefix
+	// of. Every version is supported when it is empty.
+	PuppetVersions []string `yaml:"puppet_versions"`
+
+	// Capabilities are what the tool can do, by name.
+	Capabilities map[string]Invocation `yaml:"capabilities"`
+
+	// Output says how to turn the output of the tool in to results.
+	Output Output `yaml:"output"`
+}
+
+// Supports returns true if the tool has the capability.
+func (s Spec) Supports(c Capability) bool {
+	_, ok := s.Capabilities[string(c)]
+	return ok
+}
+
+// SupportsPuppet returns true if the tool can be used with the given
+// version of puppet.
+func (s Spec) SupportsPuppet(version string) bool {
+	if len(s.PuppetVersions) == 0 {
+		return true
+	}
+
+	for _, v := range s.PuppetVersions {
+		if version == v || strings.HasPrefix(version, v+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Provides returns the names of the capabilities of the tool in order.
+func (s Spec) Provides() []string {
+	names := make([]string, 0, len(s.Capabilities))
+	for name := range s.Capabilities {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// LocalBinary returns the path of the binary in the package in root, and
+// false if the binary is looked up on the PATH instead. See InPackage.
+func (s Spec) LocalBinary(root string) (string, bool, error) {
+	return InPackage(s.Binary, root)
+}
+
+// InPackage returns the path of command in the package in root, and false
+// if command is not a path and so is looked up on the PATH instead. An error
+// of kind pdk_errors.InvalidPackage is returned for absolute paths and paths
+// that leave the package with ..
+func InPackage(command, root string) (string, bool, error) {
+	if !strings.ContainsAny(command, `/\`) {
+		return "", false, nil
+	}
+
+	rel, err := packagePath(command)
+	if err != nil {
+		return "", true, err
+	}
+
+	return filepath.Join(root, filepath.FromSlash(rel)), true, nil
+}
+
+// packagePath returns command as a clean slash separated path relative to
+// the package.
+func packagePath(command string) (string, error) {
+	rel := path.Clean(strings.ReplaceAll(command, `\`, "/"))
+	if path.IsAbs(rel) || filepath.IsAbs(command) || filepath.VolumeName(command) != "" || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", pdk_errors.New(pdk_errors.InvalidPackage, "%s must be a path inside the tool package, such as bin/%s", command, path.Base(rel))
+	}
+
+	return rel, nil
+}
+
+// Options controls how a tool is run.
+type Options struct {
+	// Dir is the directory of the content that the tool runs against. It is
+	// also the working directory of the tool.
+	Dir string
+
+	// Capability selects the args of one of the capabilities of the tool.
+	// They are passed after the args of the Spec. It may be empty.
+	Capability Capability
+
+	// Args are passed to the tool last.
+	Args []string
+
+	// Backend is Docker or Local. It decides how tools with an image are
+	// run.
+	Backend string
+
+	// PuppetVersion is checked against the versions that the tool supports
+	// and passed to it in PuppetVersionEnv.
+	PuppetVersion string
+
+	// Env holds extra environment variables in the form KEY=VALUE.
+	Env []string
+
+	// Stdout and Stderr receive the output of the tool as it is written.
+	// The output is captured in the result regardless.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Timeout limits how long the tool may run. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// Runner runs tools.
+type Runner interface {
+	// Run runs the tool in the package in root. An error of kind
+	// pdk_errors.ToolUnsupported is returned if the tool can not be run
+	// with opts, and one of kind pdk_errors.Tool if it exits with a
+	// non-zero exit code. The result is returned either way.
+	Run(ctx context.Context, name, root string, spec Spec, opts Options) (exec_runner.Result, error)
+}
+
+type runner struct {
+	Exec exec_runner.ExecRunner
+}
+
+func (r *runner) Run(ctx context.Context, name, root string, spec Spec, opts Options) (exec_runner.Result, error) {
+	if !spec.SupportsPuppet(opts.PuppetVersion) {
+		return exec_runner.Result{}, pdk_errors.New(pdk_errors.ToolUnsupported, "%s supports puppet %s, but puppet_version is %s", name, strings.Join(spec.PuppetVersions, ", "), opts.PuppetVersion)
+	}
+
+	args := append([]string{}, spec.Args...)
+	if opts.Capability != "" {
+		invocation, ok := spec.Capabilities[string(opts.Capability)]
+		if !ok {
+			return exec_runner.Result{}, pdk_errors.New(pdk_errors.ToolUnsupported, "%s can not %s content. It provides: %s", name, opts.Capability, strings.Join(spec.Provides(), ", "))
+		}
+
+		args = append(args, invocation.Args...)
+	}
+	args = append(args, opts.Args...)
+
+	env := []string{PuppetVersionEnv + "=" + opts.PuppetVersion}
+	env = append(env, opts.Env...)
+
+	command, args, err := commandLine(name, root, spec, opts.Backend, opts.Dir, env, args)
+	if err != nil {
+		return exec_runner.Result{}, err
+	}
+
+	log.Debug().Str("tool", name).Str("command", command).Strs("args", args).Str("dir", opts.Dir).Msg("running tool")
+
+	result, err := r.Exec.Run(ctx, command, args, exec_runner.Options{
+		Dir:     opts.Dir,
+		Env:     append([]string{DirEnv + "=" + root}, env...),
+		Stdout:  opts.Stdout,
+		Stderr:  opts.Stderr,
+		Timeout: opts.Timeout,
+	})
+	if err != nil {
+		if command == Docker && network.Offline() && missingImage(result) {
+			return result, pdk_errors.Wrap(pdk_errors.Offline, err, "%s runs in the image %s, which is not available locally and cannot be pulled in offline mode. Pull it with 'docker pull %s' or load it with 'pdk bundle import'", name, spec.Image, spec.Image)
+		}
+
+		if ctx.Err() != nil || result.ExitCode <= 0 {
+			return result, pdk_errors.Wrap(pdk_errors.KindOf(err), err, "could not run %s", name)
+		}
+
+		return result, pdk_errors.Wrap(pdk_errors.Tool, err, "%s failed", name)
+	}
+
+	return result, nil
+}
+
+// missingImage returns true if docker run failed because the image is not
+// available locally.
+func missingImage(result exec_runner.Result) bool {
+	stderr := string(result.Stderr)
+	return result.ExitCode == dockerRunError && (strings.Contains(stderr, "No such image") || strings.Contains(stderr, "Unable to find image"))
+}
+
+// commandLine returns the command that runs the tool and its arguments. Image
+// tools are run with docker run, which is told not to pull the image in
+// offline mode.
+func commandLine(name, root string, spec Spec, backend, dir string, env, args []string) (string, []string, error) {
+	if spec.Image == "" {
+		binary, local, err := spec.LocalBinary(root)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if local {
+			return binary, args, nil
+		}
+
+		return spec.Binary, args, nil
+	}
+
+	if backend != Docker {
+		return "", nil, pdk_errors.New(pdk_errors.ToolUnsupported, "%s runs in the image %s, which needs the %s backend. The backend is %s", name, spec.Image, Docker, backend)
+	}
+
+	run := []string{
+		"run", "--rm",
+		"--volume", fmt.Sprintf("%s:%s", dir, codeMount),
+		"--volume", fmt.Sprintf("%s:%s:ro", root, toolMount),
+		"--workdir", codeMount,
+		"--env", DirEnv + "=" + toolMount,
+	}
+
+	if network.Offline() {
+		run = append(run, "--pull", "never")
+	}
+
+	for _, e := range env {
+		run = append(run, "--env", e)
+	}
+
+	if spec.Entrypoint != "" {
+		entrypoint := spec.Entrypoint
+		if strings.ContainsAny(entrypoint, `/\`) {
+			rel, err := packagePath(entrypoint)
+			if err != nil {
+				return "", nil, err
+			}
+
+			entrypoint = path.Join(toolMount, rel)
+		}
+
+		run = append(run, "--entrypoint", entrypoint)
+	}
+
+	return Docker, append(append(run, spec.Image), args...), nil
+}
+
+// NewRunner returns a Runner that runs tools with exec_runner.
+func NewRunner() Runner {
+	return &runner{Exec: exec_runner.NewExecRunner()}
+}
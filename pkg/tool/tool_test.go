@@ -0,0 +1,226 @@
+package tool
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chelnak/pdk/pkg/exec_runner"
+	"github.com/chelnak/pdk/pkg/network"
+	"github.com/chelnak/pdk/pkg/pdk_errors"
+)
+
+var lint = Spec{
+	Binary: "bin/lint",
+	Args:   []string{"--strict"},
+	Capabilities: map[string]Invocation{
+		"validate": {Args: []string{"check"}},
+	},
+}
+
+func TestRunLocalBinary(t *testing.T) {
+	fake := &exec_runner.FakeExecRunner{}
+	r := &runner{Exec: fake}
+	root := filepath.FromSlash("/tools/example/lint/0.1.0")
+
+	_, err := r.Run(context.Background(), "example/lint", root, lint, Options{
+		Dir:           "/code",
+		Capability:    Validate,
+		Args:          []string{"manifests"},
+		Backend:       Local,
+		PuppetVersion: "7.14.0",
+		Env:           []string{"EXTRA=1"},
+		Timeout:       time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Run() returned an error: %v", err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("got %d calls, want 1", len(calls))
+	}
+
+	call := calls[0]
+	if want := filepath.Join(root, "bin", "lint"); call.Name != want {
+		t.Errorf("got command %q, want %q", call.Name, want)
+	}
+	if want := []string{"--strict", "check", "manifests"}; !reflect.DeepEqual(call.Args, want) {
+		t.Errorf("got args %q, want %q", call.Args, want)
+	}
+	if call.Opts.Dir != "/code" {
+		t.Errorf("got dir %q, want %q", call.Opts.Dir, "/code")
+	}
+	if want := []string{DirEnv + "=" + root, PuppetVersionEnv + "=7.14.0", "EXTRA=1"}; !reflect.DeepEqual(call.Opts.Env, want) {
+		t.Errorf("got env %q, want %q", call.Opts.Env, want)
+	}
+	if call.Opts.Timeout != time.Minute {
+		t.Errorf("got timeout %s, want %s", call.Opts.Timeout, time.Minute)
+	}
+}
+
+func TestRunImage(t *testing.T) {
+	fake := &exec_runner.FakeExecRunner{}
+	r := &runner{Exec: fake}
+
+	spec := Spec{
+		Image:      "example/lint:1",
+		Entrypoint: "bin/lint",
+		Args:       []string{"--strict"},
+	}
+
+	_, err := r.Run(context.Background(), "example/lint", "/tools/lint", spec, Options{
+		Dir:           "/work/module",
+		Args:          []string{"manifests"},
+		Backend:       Docker,
+		PuppetVersion: "7.14.0",
+		Timeout:       time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Run() returned an error: %v", err)
+	}
+
+	call := fake.Calls()[0]
+	if call.Name != Docker {
+		t.Errorf("got command %q, want %q", call.Name, Docker)
+	}
+
+	want := []string{
+		"run", "--rm",
+		"--volume", "/work/module:/code",
+		"--volume", "/tools/lint:/tool:ro",
+		"--workdir", "/code",
+		"--env", "PDK_TOOL_DIR=/tool",
+		"--env", "PDK_PUPPET_VERSION=7.14.0",
+		"--entrypoint", "/tool/bin/lint",
+		"example/lint:1",
+		"--strict", "manifests",
+	}
+	if !reflect.DeepEqual(call.Args, want) {
+		t.Errorf("got args\n%q\nwant\n%q", call.Args, want)
+	}
+	if call.Opts.Timeout != time.Minute {
+		t.Errorf("got timeout %s, want %s", call.Opts.Timeout, time.Minute)
+	}
+}
+
+func TestRunUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+		opts Options
+	}{
+		{
+			name: "image without docker",
+			spec: Spec{Image: "example/lint:1"},
+			opts: Options{Backend: Local},
+		},
+		{
+			name: "puppet version",
+			spec: Spec{Binary: "lint", PuppetVersions: []string{"6"}},
+			opts: Options{Backend: Local, PuppetVersion: "7.14.0"},
+		},
+		{
+			name: "capability",
+			spec: lint,
+			opts: Options{Backend: Local, Capability: Format},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &exec_runner.FakeExecRunner{}
+			r := &runner{Exec: fake}
+
+			_, err := r.Run(context.Background(), "example/lint", "/tools/lint", tt.spec, tt.opts)
+			if kind := pdk_errors.KindOf(err); kind != pdk_errors.ToolUnsupported {
+				t.Errorf("got kind %s, want %s", kind.Code, pdk_errors.ToolUnsupported.Code)
+			}
+
+			if calls := fake.Calls(); len(calls) != 0 {
+				t.Errorf("got %d calls, want none", len(calls))
+			}
+		})
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	fake := &exec_runner.FakeExecRunner{
+		Handler: func(ctx context.Context, call exec_runner.FakeCall) (exec_runner.Result, error) {
+			return exec_runner.Result{ExitCode: 2}, &exec_runner.ExitError{Name: call.Name, ExitCode: 2}
+		},
+	}
+	r := &runner{Exec: fake}
+
+	result, err := r.Run(context.Background(), "example/lint", "/tools/lint", lint, Options{Backend: Local})
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.Tool {
+		t.Errorf("got kind %s, want %s", kind.Code, pdk_errors.Tool.Code)
+	}
+	if result.ExitCode != 2 {
+		t.Errorf("got exit code %d, want 2", result.ExitCode)
+	}
+}
+
+func TestRunOutsidePackage(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+		opts Options
+	}{
+		{name: "parent binary", spec: Spec{Binary: "../lint"}, opts: Options{Backend: Local}},
+		{name: "nested parent binary", spec: Spec{Binary: "bin/../../lint"}, opts: Options{Backend: Local}},
+		{name: "absolute binary", spec: Spec{Binary: "/usr/bin/lint"}, opts: Options{Backend: Local}},
+		{name: "parent entrypoint", spec: Spec{Image: "example/lint:1", Entrypoint: "../lint"}, opts: Options{Backend: Docker}},
+		{name: "absolute entrypoint", spec: Spec{Image: "example/lint:1", Entrypoint: "/bin/sh"}, opts: Options{Backend: Docker}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &exec_runner.FakeExecRunner{}
+			r := &runner{Exec: fake}
+
+			_, err := r.Run(context.Background(), "example/lint", "/tools/lint", tt.spec, tt.opts)
+			if kind := pdk_errors.KindOf(err); kind != pdk_errors.InvalidPackage {
+				t.Errorf("got kind %s, want %s: %v", kind.Code, pdk_errors.InvalidPackage.Code, err)
+			}
+
+			if calls := fake.Calls(); len(calls) != 0 {
+				t.Errorf("got %d calls, want none", len(calls))
+			}
+		})
+	}
+}
+
+func TestRunImageOffline(t *testing.T) {
+	network.SetOffline(true)
+	defer network.SetOffline(false)
+
+	fake := &exec_runner.FakeExecRunner{
+		Handler: func(ctx context.Context, call exec_runner.FakeCall) (exec_runner.Result, error) {
+			stderr := []byte("Error response from daemon: No such image: example/lint:1\n")
+			return exec_runner.Result{ExitCode: 125, Stderr: stderr}, &exec_runner.ExitError{Name: call.Name, ExitCode: 125, Stderr: stderr}
+		},
+	}
+	r := &runner{Exec: fake}
+
+	_, err := r.Run(context.Background(), "example/lint", "/tools/lint", Spec{Image: "example/lint:1"}, Options{Dir: "/work/module", Backend: Docker})
+	if kind := pdk_errors.KindOf(err); kind != pdk_errors.Offline {
+		t.Errorf("got kind %s, want %s: %v", kind.Code, pdk_errors.Offline.Code, err)
+	}
+
+	want := []string{
+		"run", "--rm",
+		"--volume", "/work/module:/code",
+		"--volume", "/tools/lint:/tool:ro",
+		"--workdir", "/code",
+		"--env", "PDK_TOOL_DIR=/tool",
+		"--pull", "never",
+		"--env", "PDK_PUPPET_VERSION=",
+		"example/lint:1",
+	}
+	if got := fake.Calls()[0].Args; !reflect.DeepEqual(got, want) {
+		t.Errorf("got args\n%q\nwant\n%q", got, want)
+	}
+}